@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Config Settings of the service, loaded by Load. Every setting can come from the config file,
+// an environment variable or a flag, later ones overriding earlier ones.
+type Config struct {
+	HTTP            HTTP          `yaml:"http" toml:"http"`
+	GRPC            GRPC          `yaml:"grpc" toml:"grpc"`
+	TLS             TLS           `yaml:"tls" toml:"tls"`
+	Neo4j           Neo4j         `yaml:"neo4j" toml:"neo4j"`
+	NATS            NATS          `yaml:"nats" toml:"nats"`
+	Tweet           Tweet         `yaml:"tweet" toml:"tweet"`
+	JWT             JWT           `yaml:"jwt" toml:"jwt"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" toml:"shutdownTimeout"`
+}
+
+type HTTP struct {
+	Address string `yaml:"address" toml:"address"`
+}
+
+type GRPC struct {
+	Address string `yaml:"address" toml:"address"`
+	// PolicyFile Methods allowed per client certificate, the built-in policy when empty.
+	PolicyFile string `yaml:"policyFile" toml:"policyFile"`
+}
+
+// TLS Certificate and key of the service, used by the HTTP and gRPC servers and the gRPC clients,
+// and the CA that client and server certificates are verified against.
+type TLS struct {
+	CertFile string `yaml:"certFile" toml:"certFile"`
+	KeyFile  string `yaml:"keyFile" toml:"keyFile"`
+	CAFile   string `yaml:"caFile" toml:"caFile"`
+}
+
+type Neo4j struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     string `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+type NATS struct {
+	Host string `yaml:"host" toml:"host"`
+	Port string `yaml:"port" toml:"port"`
+	// QueryToken Secret shared with the services allowed to send queries, which carry it in the
+	// Query-Token header.
+	QueryToken string `yaml:"queryToken" toml:"queryToken"`
+}
+
+type Tweet struct {
+	Address string        `yaml:"address" toml:"address"`
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+type JWT struct {
+	SecretKey string `yaml:"secretKey" toml:"secretKey"`
+}
+
+func Default() Config {
+	return Config{
+		HTTP: HTTP{
+			Address: "0.0.0.0:8000",
+		},
+		GRPC: GRPC{
+			Address: "0.0.0.0:9001",
+		},
+		Tweet: Tweet{
+			Address: "tweet:9001",
+			Timeout: 5 * time.Second,
+		},
+		ShutdownTimeout: 30 * time.Second,
+	}
+}
+
+// ValidationError Lists every invalid setting, not only the first one.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e.Problems, "; ")
+}
+
+func (c Config) Validate() error {
+	var problems []string
+	required := func(value string, name string) {
+		if value == "" {
+			problems = append(problems, name+" is required")
+		}
+	}
+	address := func(value string, name string) {
+		if _, _, err := net.SplitHostPort(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s %q is not a host:port address", name, value))
+		}
+	}
+	positive := func(value time.Duration, name string) {
+		if value <= 0 {
+			problems = append(problems, name+" must be positive")
+		}
+	}
+
+	address(c.HTTP.Address, "http.address")
+	address(c.GRPC.Address, "grpc.address")
+	required(c.TLS.CertFile, "tls.certFile")
+	required(c.TLS.KeyFile, "tls.keyFile")
+	required(c.TLS.CAFile, "tls.caFile")
+	required(c.Neo4j.Host, "neo4j.host")
+	required(c.Neo4j.Port, "neo4j.port")
+	required(c.NATS.Host, "nats.host")
+	required(c.NATS.Port, "nats.port")
+	required(c.NATS.QueryToken, "nats.queryToken")
+	address(c.Tweet.Address, "tweet.address")
+	positive(c.Tweet.Timeout, "tweet.timeout")
+	required(c.JWT.SecretKey, "jwt.secretKey")
+	positive(c.ShutdownTimeout, "shutdownTimeout")
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func validConfig() Config {
+	c := Default()
+	c.TLS = TLS{CertFile: "cert.pem", KeyFile: "key.pem", CAFile: "ca.pem"}
+	c.Neo4j = Neo4j{Host: "neo4j", Port: "7687"}
+	c.NATS = NATS{Host: "nats", Port: "4222", QueryToken: "token"}
+	c.JWT.SecretKey = "secret"
+	return c
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(c *Config)
+		problems []string
+	}{
+		{name: "valid", change: func(c *Config) {}},
+		{name: "missing secret", change: func(c *Config) { c.JWT.SecretKey = "" }, problems: []string{"jwt.secretKey is required"}},
+		{name: "missing query token", change: func(c *Config) { c.NATS.QueryToken = "" }, problems: []string{"nats.queryToken is required"}},
+		{name: "invalid address", change: func(c *Config) { c.HTTP.Address = "8000" }, problems: []string{`http.address "8000" is not a host:port address`}},
+		{name: "non-positive durations", change: func(c *Config) {
+			c.Tweet.Timeout = 0
+			c.ShutdownTimeout = -time.Second
+		}, problems: []string{"tweet.timeout must be positive", "shutdownTimeout must be positive"}},
+		{name: "every problem", change: func(c *Config) { *c = Config{} }, problems: []string{
+			`http.address "" is not a host:port address`,
+			`grpc.address "" is not a host:port address`,
+			"tls.certFile is required",
+			"tls.keyFile is required",
+			"tls.caFile is required",
+			"neo4j.host is required",
+			"neo4j.port is required",
+			"nats.host is required",
+			"nats.port is required",
+			"nats.queryToken is required",
+			`tweet.address "" is not a host:port address`,
+			"tweet.timeout must be positive",
+			"jwt.secretKey is required",
+			"shutdownTimeout must be positive",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.change(&c)
+
+			err := c.Validate()
+			if tt.problems == nil {
+				if err != nil {
+					t.Errorf("got %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("got %v, want a ValidationError", err)
+			}
+			if fmt.Sprintf("%q", validationErr.Problems) != fmt.Sprintf("%q", tt.problems) {
+				t.Errorf("got problems %q, want %q", validationErr.Problems, tt.problems)
+			}
+		})
+	}
+}
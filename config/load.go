@@ -0,0 +1,140 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// env Environment variables of the settings, the names predate the config file.
+var env = []struct {
+	name string
+	set  func(c *Config, value string) error
+}{
+	{"HTTP_ADDRESS", setString(func(c *Config) *string { return &c.HTTP.Address })},
+	{"GRPC_ADDRESS", setString(func(c *Config) *string { return &c.GRPC.Address })},
+	{"GRPC_POLICY", setString(func(c *Config) *string { return &c.GRPC.PolicyFile })},
+	{"CERT", setString(func(c *Config) *string { return &c.TLS.CertFile })},
+	{"KEY", setString(func(c *Config) *string { return &c.TLS.KeyFile })},
+	{"CA_CERT", setString(func(c *Config) *string { return &c.TLS.CAFile })},
+	{"DB", setString(func(c *Config) *string { return &c.Neo4j.Host })},
+	{"DBPORT", setString(func(c *Config) *string { return &c.Neo4j.Port })},
+	{"DB_USER", setString(func(c *Config) *string { return &c.Neo4j.User })},
+	{"DB_PASS", setString(func(c *Config) *string { return &c.Neo4j.Password })},
+	{"NATS_HOST", setString(func(c *Config) *string { return &c.NATS.Host })},
+	{"NATS_PORT", setString(func(c *Config) *string { return &c.NATS.Port })},
+	{"NATS_QUERY_TOKEN", setString(func(c *Config) *string { return &c.NATS.QueryToken })},
+	{"TWEET_ADDRESS", setString(func(c *Config) *string { return &c.Tweet.Address })},
+	{"TWEET_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.Tweet.Timeout })},
+	{"SECRET_KEY", setString(func(c *Config) *string { return &c.JWT.SecretKey })},
+	{"SHUTDOWN_TIMEOUT", setDuration(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+}
+
+// Load Loads the config from defaults, the YAML or TOML file given by the -config flag or the
+// CONFIG_FILE environment variable, the environment and the flags in args, in that order,
+// and validates it.
+func Load(args []string) (Config, error) {
+	c := Default()
+
+	fs := flag.NewFlagSet("social-graph", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file")
+	httpAddress := fs.String("http-address", "", "HTTP listen address")
+	grpcAddress := fs.String("grpc-address", "", "gRPC listen address")
+	grpcPolicy := fs.String("grpc-policy", "", "gRPC authorization policy file")
+	tweetAddress := fs.String("tweet-address", "", "tweet service address")
+	tweetTimeout := fs.Duration("tweet-timeout", 0, "timeout of tweet service calls")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 0, "time to wait for graceful shutdown")
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+
+	if *file != "" {
+		if err := loadFile(&c, *file); err != nil {
+			return c, err
+		}
+	}
+
+	for _, e := range env {
+		if value, ok := os.LookupEnv(e.name); ok && value != "" {
+			if err := e.set(&c, value); err != nil {
+				return c, fmt.Errorf("invalid %s: %w", e.name, err)
+			}
+		}
+	}
+
+	// only flags given explicitly override the file and the environment
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "http-address":
+			c.HTTP.Address = *httpAddress
+		case "grpc-address":
+			c.GRPC.Address = *grpcAddress
+		case "grpc-policy":
+			c.GRPC.PolicyFile = *grpcPolicy
+		case "tweet-address":
+			c.Tweet.Address = *tweetAddress
+		case "tweet-timeout":
+			c.Tweet.Timeout = *tweetTimeout
+		case "shutdown-timeout":
+			c.ShutdownTimeout = *shutdownTimeout
+		}
+	})
+
+	return c, c.Validate()
+}
+
+func loadFile(c *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// unknown keys are rejected, a misspelled setting would silently keep its default
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(c)
+		if err == io.EOF {
+			// an empty file
+			err = nil
+		}
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.Decode(string(data), c)
+		if err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown keys %v", meta.Undecoded())
+		}
+	default:
+		return errors.New("config file must be .yaml, .yml or .toml: " + path)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func setString(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setDuration(field func(c *Config) *time.Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}
+}
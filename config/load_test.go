@@ -0,0 +1,202 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const yamlConfig = `
+http:
+  address: 0.0.0.0:8080
+grpc:
+  address: 0.0.0.0:9090
+tls:
+  certFile: cert.pem
+  keyFile: key.pem
+  caFile: ca.pem
+neo4j:
+  host: neo4j
+  port: "7687"
+nats:
+  host: nats
+  port: "4222"
+  queryToken: token
+tweet:
+  timeout: 3s
+jwt:
+  secretKey: secret
+`
+
+const tomlConfig = `
+[http]
+address = "0.0.0.0:8080"
+[grpc]
+address = "0.0.0.0:9090"
+[tls]
+certFile = "cert.pem"
+keyFile = "key.pem"
+caFile = "ca.pem"
+[neo4j]
+host = "neo4j"
+port = "7687"
+[nats]
+host = "nats"
+port = "4222"
+queryToken = "token"
+[tweet]
+timeout = "3s"
+[jwt]
+secretKey = "secret"
+`
+
+// clearEnv Unsets the environment variables Load reads for the duration of t.
+func clearEnv(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	for _, e := range env {
+		t.Setenv(e.name, "")
+	}
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		args    []string
+		http    string
+		grpc    string
+		timeout time.Duration
+	}{
+		{
+			name:    "yaml file over defaults",
+			file:    "config.yaml",
+			content: yamlConfig,
+			http:    "0.0.0.0:8080",
+			grpc:    "0.0.0.0:9090",
+			timeout: 3 * time.Second,
+		},
+		{
+			name:    "toml file over defaults",
+			file:    "config.toml",
+			content: tomlConfig,
+			http:    "0.0.0.0:8080",
+			grpc:    "0.0.0.0:9090",
+			timeout: 3 * time.Second,
+		},
+		{
+			name:    "environment over file",
+			file:    "config.yaml",
+			content: yamlConfig,
+			env:     map[string]string{"HTTP_ADDRESS": "0.0.0.0:8081", "TWEET_TIMEOUT": "4s"},
+			http:    "0.0.0.0:8081",
+			grpc:    "0.0.0.0:9090",
+			timeout: 4 * time.Second,
+		},
+		{
+			name:    "flags over environment",
+			file:    "config.yaml",
+			content: yamlConfig,
+			env:     map[string]string{"HTTP_ADDRESS": "0.0.0.0:8081", "GRPC_ADDRESS": "0.0.0.0:9091"},
+			args:    []string{"-http-address", "0.0.0.0:8082", "-tweet-timeout", "5s"},
+			http:    "0.0.0.0:8082",
+			grpc:    "0.0.0.0:9091",
+			timeout: 5 * time.Second,
+		},
+		{
+			name: "environment over defaults",
+			env: map[string]string{
+				"CERT": "cert.pem", "KEY": "key.pem", "CA_CERT": "ca.pem",
+				"DB": "neo4j", "DBPORT": "7687",
+				"NATS_HOST": "nats", "NATS_PORT": "4222", "NATS_QUERY_TOKEN": "token",
+				"SECRET_KEY": "secret",
+			},
+			http:    "0.0.0.0:8000",
+			grpc:    "0.0.0.0:9001",
+			timeout: 5 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file, tt.content)}, args...)
+			}
+
+			c, err := Load(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.HTTP.Address != tt.http || c.GRPC.Address != tt.grpc || c.Tweet.Timeout != tt.timeout {
+				t.Errorf("got http %s, grpc %s, tweet timeout %v, want %s, %s, %v", c.HTTP.Address, c.GRPC.Address, c.Tweet.Timeout, tt.http, tt.grpc, tt.timeout)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileFromEnvironment(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("CONFIG_FILE", writeFile(t, "config.yml", yamlConfig))
+
+	c, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.NATS.QueryToken != "token" {
+		t.Errorf("got %+v", c)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		args    []string
+		err     string
+	}{
+		{name: "unknown yaml key", file: "config.yaml", content: yamlConfig + "shutdownTimeot: 10s\n", err: "shutdownTimeot"},
+		{name: "unknown nested yaml key", file: "config.yaml", content: strings.Replace(yamlConfig, "queryToken", "queryTokn", 1), err: "queryTokn"},
+		{name: "unknown toml key", file: "config.toml", content: tomlConfig + "[tweet2]\naddress = \"tweet:9001\"\n", err: "tweet2"},
+		{name: "unsupported extension", file: "config.json", content: "{}", err: ".json"},
+		{name: "missing file", args: []string{"-config", filepath.Join(os.TempDir(), "missing.yaml")}, err: "missing.yaml"},
+		{name: "invalid environment duration", file: "config.yaml", content: yamlConfig, env: map[string]string{"SHUTDOWN_TIMEOUT": "soon"}, err: "SHUTDOWN_TIMEOUT"},
+		{name: "unknown flag", args: []string{"-port", "80"}, err: "port"},
+		{name: "invalid config", file: "config.yaml", content: "jwt:\n  secretKey: secret\n", err: "tls.certFile is required"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file, tt.content)}, args...)
+			}
+
+			_, err := Load(args)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want an error about %s", err, tt.err)
+			}
+		})
+	}
+}
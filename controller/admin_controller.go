@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"compress/gzip"
+	"errors"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"log"
+	"net/http"
+	"social-graph/controller/json"
+	"social-graph/export"
+	"social-graph/importer"
+	"social-graph/saga"
+	"social-graph/service"
+	"social-graph/snapshot"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxExportDepth = 3
+	maxDeadLetters = 100
+)
+
+// AdminController Endpoints available only to ROLE_ADMIN users.
+type AdminController struct {
+	socialGraphService service.SocialGraphService
+	deadLetters        *saga.DeadLetterQueue
+	tracer             trace.Tracer
+}
+
+func NewAdminController(socialGraphService *service.SocialGraphService, deadLetters *saga.DeadLetterQueue, tracer trace.Tracer) *AdminController {
+	return &AdminController{
+		*socialGraphService,
+		deadLetters,
+		tracer,
+	}
+}
+
+func (ac *AdminController) ExportGraph(w http.ResponseWriter, req *http.Request) {
+	ctx, span := ac.tracer.Start(req.Context(), "AdminController.ExportGraph")
+	defer span.End()
+	query := req.URL.Query()
+
+	format, err := export.GetFormat(query.Get("format"))
+	if err != nil {
+		http.Error(w, "Format must be graphml, gexf or dot", 400)
+		return
+	}
+
+	username := query.Get("username")
+	depth := 1
+	if v := query.Get("depth"); v != "" {
+		depth, err = strconv.Atoi(v)
+		if err != nil || depth < 1 || depth > maxExportDepth {
+			http.Error(w, "Depth must be between 1 and "+strconv.Itoa(maxExportDepth), 400)
+			return
+		}
+	}
+
+	filename := "social-graph"
+	if username != "" {
+		filename += "-" + username
+	}
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+"."+format.Extension+`"`)
+
+	// the response is already streaming, so errors can only be logged
+	err = ac.socialGraphService.ExportGraph(ctx, format.NewWriter(w), username, depth)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		log.Println(err)
+		return
+	}
+}
+
+func (ac *AdminController) SaveSnapshot(w http.ResponseWriter, req *http.Request) {
+	ctx, span := ac.tracer.Start(req.Context(), "AdminController.SaveSnapshot")
+	defer span.End()
+
+	filename := "social-graph-" + time.Now().UTC().Format("20060102T150405Z") + ".jsonl"
+	var err error
+	switch req.URL.Query().Get("compress") {
+	case "":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		_, err = ac.socialGraphService.SaveSnapshot(ctx, w)
+	case "gzip":
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.gz"`)
+		gz := gzip.NewWriter(w)
+		_, err = ac.socialGraphService.SaveSnapshot(ctx, gz)
+		if err == nil {
+			err = gz.Close()
+		}
+	default:
+		http.Error(w, "Compress must be gzip", 400)
+		return
+	}
+
+	// the response is already streaming, so errors can only be logged
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		log.Println(err)
+		return
+	}
+}
+
+func (ac *AdminController) RestoreSnapshot(w http.ResponseWriter, req *http.Request) {
+	ctx, span := ac.tracer.Start(req.Context(), "AdminController.RestoreSnapshot")
+	defer span.End()
+
+	stats, err := ac.socialGraphService.RestoreSnapshot(ctx, req.Body)
+	var formatErr *snapshot.FormatError
+	if errors.As(err, &formatErr) {
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	err = json.EncodeJson(w, stats)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+}
+
+// ImportFollows Imports from/to pairs sent as text/csv or application/x-ndjson,
+// the format query parameter overrides the content type.
+func (ac *AdminController) ImportFollows(w http.ResponseWriter, req *http.Request) {
+	ctx, span := ac.tracer.Start(req.Context(), "AdminController.ImportFollows")
+	defer span.End()
+	query := req.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		contentType := req.Header.Get("Content-Type")
+		switch {
+		case strings.HasPrefix(contentType, "text/csv"):
+			format = "csv"
+		case strings.HasPrefix(contentType, "application/x-ndjson"):
+			format = "ndjson"
+		}
+	}
+
+	dryRun := false
+	if v := query.Get("dryRun"); v != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "DryRun must be true or false", 400)
+			return
+		}
+	}
+
+	report, err := ac.socialGraphService.ImportFollows(ctx, req.Body, format, dryRun)
+	if errors.Is(err, importer.ErrUnsupportedFormat) {
+		http.Error(w, err.Error(), 415)
+		return
+	}
+	if err != nil {
+		// the batches saved before the error are in the report
+		span.SetStatus(codes.Error, err.Error())
+		json.EncodeJsonWithStatus(w, report, 500)
+		return
+	}
+	err = json.EncodeJson(w, report)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+}
+
+func (ac *AdminController) GetDeadLetters(w http.ResponseWriter, req *http.Request) {
+	ctx, span := ac.tracer.Start(req.Context(), "AdminController.GetDeadLetters")
+	defer span.End()
+
+	limit := maxDeadLetters
+	if v := req.URL.Query().Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxDeadLetters {
+			http.Error(w, "Limit must be between 1 and "+strconv.Itoa(maxDeadLetters), 400)
+			return
+		}
+	}
+
+	letters, err := ac.deadLetters.List(ctx, limit)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	err = json.EncodeJson(w, letters)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+}
+
+// ReplayDeadLetter Publishes the dead letter to its original subject, for example after
+// the participant that couldn't handle it was fixed.
+func (ac *AdminController) ReplayDeadLetter(w http.ResponseWriter, req *http.Request) {
+	ctx, span := ac.tracer.Start(req.Context(), "AdminController.ReplayDeadLetter")
+	defer span.End()
+
+	sequence, err := strconv.ParseUint(mux.Vars(req)["sequence"], 10, 64)
+	if err != nil {
+		http.Error(w, "Sequence must be a number", 400)
+		return
+	}
+
+	err = ac.deadLetters.Replay(ctx, sequence)
+	if errors.Is(err, saga.ErrDeadLetterNotFound) {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), 500)
+		return
+	}
+}
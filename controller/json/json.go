@@ -7,12 +7,17 @@ import (
 )
 
 func EncodeJson(w http.ResponseWriter, v interface{}) error {
+	return EncodeJsonWithStatus(w, v, http.StatusOK)
+}
+
+func EncodeJsonWithStatus(w http.ResponseWriter, v interface{}, status int) error {
 	js, err := json.Marshal(v)
 	if err != nil {
 		return err
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	w.Write(js)
 
 	return nil
@@ -7,12 +7,13 @@ import (
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 	"net/http"
-	"os"
 	"social-graph/model"
 	"time"
 )
 
-func ExtractJWTUserMiddleware(tracer trace.Tracer) mux.MiddlewareFunc {
+// ExtractJWTUserMiddleware Puts the user of the token in the Authorization header, signed with
+// secretKey, into the request context.
+func ExtractJWTUserMiddleware(tracer trace.Tracer, secretKey string) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			newCtx, span := tracer.Start(r.Context(), "ExtractJWTUserMiddleware")
@@ -23,7 +24,7 @@ func ExtractJWTUserMiddleware(tracer trace.Tracer) mux.MiddlewareFunc {
 
 				_, parseSpan := tracer.Start(newCtx, "jwt.Parse")
 				token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-					return []byte(os.Getenv("SECRET_KEY")), nil
+					return []byte(secretKey), nil
 				})
 				parseSpan.End()
 
@@ -48,3 +49,24 @@ func ExtractJWTUserMiddleware(tracer trace.Tracer) mux.MiddlewareFunc {
 		})
 	}
 }
+
+// RequireRoleMiddleware Rejects requests whose user, set by ExtractJWTUserMiddleware, has none of the roles.
+func RequireRoleMiddleware(tracer trace.Tracer, roles ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, span := tracer.Start(r.Context(), "RequireRoleMiddleware")
+			defer span.End()
+
+			authUser := r.Context().Value("authUser").(model.AuthUser)
+			for _, role := range roles {
+				if authUser.Role == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			span.SetStatus(codes.Error, "Forbidden role "+authUser.Role)
+			http.Error(w, "Forbidden", 403)
+		})
+	}
+}
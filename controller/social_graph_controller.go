@@ -1,13 +1,17 @@
 package controller
 
 import (
+	"errors"
 	"github.com/gorilla/mux"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 	"net/http"
 	"social-graph/controller/json"
 	"social-graph/model"
+	"social-graph/repository"
 	"social-graph/service"
+	"strconv"
+	"time"
 )
 
 type SocialGraphController struct {
@@ -34,8 +38,13 @@ func (sgc *SocialGraphController) CreateFollow(w http.ResponseWriter, req *http.
 		return
 	}
 	err := sgc.socialGraphService.CreateFollow(ctx, authUser.Username, toUsername)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		http.Error(w, err.Error(), 404)
+		return
+	}
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), 500)
 		return
 	}
 
@@ -51,6 +60,47 @@ func (sgc *SocialGraphController) RemoveFollow(w http.ResponseWriter, req *http.
 		return
 	}
 }
+func (sgc *SocialGraphController) RemoveFollower(w http.ResponseWriter, req *http.Request) {
+	ctx, span := sgc.tracer.Start(req.Context(), "SocialGraphController.RemoveFollower")
+	defer span.End()
+	follower := mux.Vars(req)["username"]
+	authUser := ctx.Value("authUser").(model.AuthUser)
+	err := sgc.socialGraphService.RemoveFollower(ctx, authUser.Username, follower)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), 500)
+		return
+	}
+}
+
+// UpdatePrivacy Going private responds with the existing followers, any of them can then be
+// removed through RemoveFollower.
+func (sgc *SocialGraphController) UpdatePrivacy(w http.ResponseWriter, req *http.Request) {
+	ctx, span := sgc.tracer.Start(req.Context(), "SocialGraphController.UpdatePrivacy")
+	defer span.End()
+	authUser := ctx.Value("authUser").(model.AuthUser)
+	privacy, err := json.DecodeJson[model.Privacy](req.Body)
+	if err != nil {
+		http.Error(w, "Body must be {\"private\": true|false}", 400)
+		return
+	}
+	change, err := sgc.socialGraphService.UpdateUser(ctx, authUser.Username, privacy.Private)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	err = json.EncodeJson(w, change)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+}
+
 func (sgc *SocialGraphController) GetFollowing(w http.ResponseWriter, req *http.Request) {
 	ctx, span := sgc.tracer.Start(req.Context(), "SocialGraphController.GetFollowing")
 	defer span.End()
@@ -138,6 +188,7 @@ func (sgc *SocialGraphController) AcceptRejectFollowRequest(w http.ResponseWrite
 	err := sgc.socialGraphService.AcceptRejectFollowRequest(ctx, from, authUser.Username, approved.Approved)
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), 500)
 		return
 	}
 }
@@ -189,3 +240,102 @@ func (sgc *SocialGraphController) GetRecommendationsProfile(w http.ResponseWrite
 		return
 	}
 }
+
+func (sgc *SocialGraphController) GetFollowerGrowth(w http.ResponseWriter, req *http.Request) {
+	ctx, span := sgc.tracer.Start(req.Context(), "SocialGraphController.GetFollowerGrowth")
+	defer span.End()
+	authUser := ctx.Value("authUser").(model.AuthUser)
+	query := req.URL.Query()
+
+	var interval time.Duration
+	switch query.Get("interval") {
+	case "", "day":
+		interval = 24 * time.Hour
+	case "hour":
+		interval = time.Hour
+	default:
+		http.Error(w, "Interval must be hour or day", 400)
+		return
+	}
+
+	to := time.Now()
+	if v := query.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "Invalid to, expected RFC3339 time", 400)
+			return
+		}
+		to = t
+	}
+	from := to.Add(-30 * interval)
+	if v := query.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "Invalid from, expected RFC3339 time", 400)
+			return
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		http.Error(w, "From must be before to", 400)
+		return
+	}
+	if to.Sub(from)/interval > service.MaxFollowerGrowthBuckets {
+		http.Error(w, "Time range too large for interval", 400)
+		return
+	}
+
+	growth, err := sgc.socialGraphService.GetFollowerGrowth(ctx, authUser.Username, from, to, interval)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	err = json.EncodeJson(w, growth)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+}
+
+func (sgc *SocialGraphController) GetEgoNetwork(w http.ResponseWriter, req *http.Request) {
+	ctx, span := sgc.tracer.Start(req.Context(), "SocialGraphController.GetEgoNetwork")
+	defer span.End()
+	authUser := ctx.Value("authUser").(model.AuthUser)
+	username := mux.Vars(req)["username"]
+	query := req.URL.Query()
+
+	var err error
+	depth := service.MaxEgoNetworkDepth
+	if v := query.Get("depth"); v != "" {
+		depth, err = strconv.Atoi(v)
+		if err != nil || depth < 1 || depth > service.MaxEgoNetworkDepth {
+			http.Error(w, "Depth must be 1 or 2", 400)
+			return
+		}
+	}
+	limit := service.DefaultEgoNetworkLimit
+	if v := query.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > service.MaxEgoNetworkLimit {
+			http.Error(w, "Limit must be between 1 and "+strconv.Itoa(service.MaxEgoNetworkLimit), 400)
+			return
+		}
+	}
+
+	network, err := sgc.socialGraphService.GetEgoNetwork(ctx, authUser.Username, username, depth, limit)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	err = json.EncodeJson(w, network)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+}
@@ -0,0 +1,40 @@
+package export
+
+import (
+	"bufio"
+	"social-graph/model"
+	"strconv"
+	"strings"
+)
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+type dotWriter struct {
+	w *bufio.Writer
+}
+
+func (d *dotWriter) Begin() error {
+	_, err := d.w.WriteString("digraph social_graph {\n")
+	return err
+}
+
+func (d *dotWriter) Node(user model.User) error {
+	_, err := d.w.WriteString(`  "` + dotEscaper.Replace(user.Username) + `" [private=` + strconv.FormatBool(user.IsPrivate) + "];\n")
+	return err
+}
+
+func (d *dotWriter) Edge(follow model.Follow) error {
+	attributes := `type="` + edgeType(follow) + `"`
+	if follow.Request {
+		attributes += ", style=dashed"
+	}
+	_, err := d.w.WriteString(`  "` + dotEscaper.Replace(follow.From) + `" -> "` + dotEscaper.Replace(follow.To) + `" [` + attributes + "];\n")
+	return err
+}
+
+func (d *dotWriter) End() error {
+	if _, err := d.w.WriteString("}\n"); err != nil {
+		return err
+	}
+	return d.w.Flush()
+}
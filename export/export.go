@@ -0,0 +1,62 @@
+package export
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"social-graph/model"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported export format")
+
+// GraphWriter Writes a graph in some file format. All nodes are written before the first edge.
+type GraphWriter interface {
+	Begin() error
+	Node(user model.User) error
+	Edge(follow model.Follow) error
+	End() error
+}
+
+type Format struct {
+	ContentType string
+	Extension   string
+	newWriter   func(w *bufio.Writer) GraphWriter
+}
+
+var formats = map[string]Format{
+	"graphml": {
+		ContentType: "application/graphml+xml",
+		Extension:   "graphml",
+		newWriter:   func(w *bufio.Writer) GraphWriter { return &graphMLWriter{w: w} },
+	},
+	"gexf": {
+		ContentType: "application/gexf+xml",
+		Extension:   "gexf",
+		newWriter:   func(w *bufio.Writer) GraphWriter { return &gexfWriter{w: w} },
+	},
+	"dot": {
+		ContentType: "text/vnd.graphviz",
+		Extension:   "dot",
+		newWriter:   func(w *bufio.Writer) GraphWriter { return &dotWriter{w: w} },
+	},
+}
+
+func GetFormat(name string) (Format, error) {
+	f, ok := formats[name]
+	if !ok {
+		return Format{}, ErrUnsupportedFormat
+	}
+	return f, nil
+}
+
+// NewWriter Returns a buffered GraphWriter, End flushes it to w.
+func (f Format) NewWriter(w io.Writer) GraphWriter {
+	return f.newWriter(bufio.NewWriter(w))
+}
+
+func edgeType(follow model.Follow) string {
+	if follow.Request {
+		return "FOLLOWS_REQUEST"
+	}
+	return "FOLLOWS"
+}
@@ -0,0 +1,164 @@
+package export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"social-graph/model"
+	"strings"
+	"testing"
+)
+
+func writeGraph(t *testing.T, format string, users []model.User, follows []model.Follow) string {
+	f, err := GetFormat(format)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w := f.NewWriter(&buf)
+	if err := w.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range users {
+		if err := w.Node(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, follow := range follows {
+		if err := w.Edge(follow); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.End(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// checkXML Fails unless document is well-formed XML.
+func checkXML(t *testing.T, document string) {
+	d := xml.NewDecoder(strings.NewReader(document))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("invalid XML: %v\n%s", err, document)
+		}
+	}
+}
+
+func TestGetFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		err         error
+	}{
+		{"graphml", "application/graphml+xml", nil},
+		{"gexf", "application/gexf+xml", nil},
+		{"dot", "text/vnd.graphviz", nil},
+		{"csv", "", ErrUnsupportedFormat},
+		{"", "", ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := GetFormat(tt.name)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if f.ContentType != tt.contentType {
+				t.Errorf("got content type %q, want %q", f.ContentType, tt.contentType)
+			}
+		})
+	}
+}
+
+func TestWriters(t *testing.T) {
+	users := []model.User{
+		{Username: "alice", IsPrivate: false},
+		{Username: `b<o>b&"`, IsPrivate: true},
+	}
+	follows := []model.Follow{
+		{From: "alice", To: `b<o>b&"`},
+		{From: `b<o>b&"`, To: "alice", Request: true},
+	}
+
+	tests := []struct {
+		format  string
+		users   []model.User
+		follows []model.Follow
+		xml     bool
+		want    []string
+	}{
+		{
+			format:  "graphml",
+			users:   users,
+			follows: follows,
+			xml:     true,
+			want: []string{
+				`<node id="alice"><data key="private">false</data></node>`,
+				`<node id="b&lt;o&gt;b&amp;&#34;"><data key="private">true</data></node>`,
+				`<edge source="alice" target="b&lt;o&gt;b&amp;&#34;"><data key="type">FOLLOWS</data></edge>`,
+				`<edge source="b&lt;o&gt;b&amp;&#34;" target="alice"><data key="type">FOLLOWS_REQUEST</data></edge>`,
+			},
+		},
+		{
+			format:  "gexf",
+			users:   users,
+			follows: follows,
+			xml:     true,
+			want: []string{
+				`<node id="alice" label="alice"><attvalues><attvalue for="private" value="false"/></attvalues></node>`,
+				`<edge id="0" source="alice" target="b&lt;o&gt;b&amp;&#34;">`,
+				`<edge id="1" source="b&lt;o&gt;b&amp;&#34;" target="alice"><attvalues><attvalue for="type" value="FOLLOWS_REQUEST"/>`,
+			},
+		},
+		{
+			format: "gexf",
+			users:  users,
+			xml:    true,
+			want:   []string{"</nodes>\n    <edges>\n    </edges>"},
+		},
+		{
+			format: "gexf",
+			xml:    true,
+			want:   []string{"<nodes>\n    </nodes>\n    <edges>\n    </edges>"},
+		},
+		{
+			format:  "dot",
+			users:   users,
+			follows: follows,
+			want: []string{
+				"digraph social_graph {\n" +
+					"  \"alice\" [private=false];\n" +
+					"  \"b<o>b&\\\"\" [private=true];\n" +
+					"  \"alice\" -> \"b<o>b&\\\"\" [type=\"FOLLOWS\"];\n" +
+					"  \"b<o>b&\\\"\" -> \"alice\" [type=\"FOLLOWS_REQUEST\", style=dashed];\n" +
+					"}\n",
+			},
+		},
+		{
+			format: "dot",
+			users:  []model.User{{Username: `back\slash`}},
+			want:   []string{`"back\\slash" [private=false];`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got := writeGraph(t, tt.format, tt.users, tt.follows)
+			if tt.xml {
+				checkXML(t, got)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("output doesn't contain %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
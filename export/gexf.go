@@ -0,0 +1,61 @@
+package export
+
+import (
+	"bufio"
+	"encoding/xml"
+	"social-graph/model"
+	"strconv"
+)
+
+type gexfWriter struct {
+	w *bufio.Writer
+	// edges Number of edges written so far, GEXF requires every edge to have an id.
+	edges int
+}
+
+func (g *gexfWriter) Begin() error {
+	_, err := g.w.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<gexf xmlns="http://gexf.net/1.3" version="1.3">
+  <graph defaultedgetype="directed" mode="static">
+    <attributes class="node">
+      <attribute id="private" title="private" type="boolean"/>
+    </attributes>
+    <attributes class="edge">
+      <attribute id="type" title="type" type="string"/>
+    </attributes>
+    <nodes>
+`)
+	return err
+}
+
+func (g *gexfWriter) Node(user model.User) error {
+	g.w.WriteString(`      <node id="`)
+	xml.EscapeText(g.w, []byte(user.Username))
+	g.w.WriteString(`" label="`)
+	xml.EscapeText(g.w, []byte(user.Username))
+	_, err := g.w.WriteString(`"><attvalues><attvalue for="private" value="` + strconv.FormatBool(user.IsPrivate) + "\"/></attvalues></node>\n")
+	return err
+}
+
+func (g *gexfWriter) Edge(follow model.Follow) error {
+	if g.edges == 0 {
+		g.w.WriteString("    </nodes>\n    <edges>\n")
+	}
+	g.w.WriteString(`      <edge id="` + strconv.Itoa(g.edges) + `" source="`)
+	xml.EscapeText(g.w, []byte(follow.From))
+	g.w.WriteString(`" target="`)
+	xml.EscapeText(g.w, []byte(follow.To))
+	_, err := g.w.WriteString(`"><attvalues><attvalue for="type" value="` + edgeType(follow) + "\"/></attvalues></edge>\n")
+	g.edges++
+	return err
+}
+
+func (g *gexfWriter) End() error {
+	if g.edges == 0 {
+		g.w.WriteString("    </nodes>\n    <edges>\n")
+	}
+	if _, err := g.w.WriteString("    </edges>\n  </graph>\n</gexf>\n"); err != nil {
+		return err
+	}
+	return g.w.Flush()
+}
@@ -0,0 +1,45 @@
+package export
+
+import (
+	"bufio"
+	"encoding/xml"
+	"social-graph/model"
+	"strconv"
+)
+
+type graphMLWriter struct {
+	w *bufio.Writer
+}
+
+func (g *graphMLWriter) Begin() error {
+	_, err := g.w.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="private" for="node" attr.name="private" attr.type="boolean"/>
+  <key id="type" for="edge" attr.name="type" attr.type="string"/>
+  <graph id="social-graph" edgedefault="directed">
+`)
+	return err
+}
+
+func (g *graphMLWriter) Node(user model.User) error {
+	g.w.WriteString(`    <node id="`)
+	xml.EscapeText(g.w, []byte(user.Username))
+	_, err := g.w.WriteString(`"><data key="private">` + strconv.FormatBool(user.IsPrivate) + "</data></node>\n")
+	return err
+}
+
+func (g *graphMLWriter) Edge(follow model.Follow) error {
+	g.w.WriteString(`    <edge source="`)
+	xml.EscapeText(g.w, []byte(follow.From))
+	g.w.WriteString(`" target="`)
+	xml.EscapeText(g.w, []byte(follow.To))
+	_, err := g.w.WriteString(`"><data key="type">` + edgeType(follow) + "</data></edge>\n")
+	return err
+}
+
+func (g *graphMLWriter) End() error {
+	if _, err := g.w.WriteString("  </graph>\n</graphml>\n"); err != nil {
+		return err
+	}
+	return g.w.Flush()
+}
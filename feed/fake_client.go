@@ -0,0 +1,50 @@
+package feed
+
+import (
+	"context"
+	"sync"
+)
+
+type Call struct {
+	From string
+	To   string
+}
+
+// FakeClient Client recording its calls instead of reaching the tweet service, for tests.
+type FakeClient struct {
+	mu       sync.Mutex
+	updates  []Call
+	removals []Call
+	// Err Returned from every call when set, the call is still recorded.
+	Err error
+}
+
+func NewFakeClient() *FakeClient {
+	return &FakeClient{}
+}
+
+func (f *FakeClient) UpdateFeed(_ context.Context, from string, to string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.updates = append(f.updates, Call{From: from, To: to})
+	return f.Err
+}
+
+func (f *FakeClient) RemoveFromFeed(_ context.Context, from string, to string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.removals = append(f.removals, Call{From: from, To: to})
+	return f.Err
+}
+
+func (f *FakeClient) Updates() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.updates...)
+}
+
+func (f *FakeClient) Removals() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.removals...)
+}
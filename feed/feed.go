@@ -0,0 +1,11 @@
+package feed
+
+import "context"
+
+// Client Keeps the feeds in the tweet service in sync with the social graph.
+type Client interface {
+	// UpdateFeed Adds the tweets of to to the feed of from.
+	UpdateFeed(ctx context.Context, from string, to string) error
+	// RemoveFromFeed Removes the tweets of to from the feed of from.
+	RemoveFromFeed(ctx context.Context, from string, to string) error
+}
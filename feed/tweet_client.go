@@ -0,0 +1,143 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"github.com/FTN-TwitterClone/grpc-stubs/proto/tweet"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"social-graph/config"
+	"social-graph/messaging"
+	"social-graph/resilience"
+	"social-graph/tls"
+	"time"
+)
+
+const (
+	breakerFailureThreshold = 5
+	breakerOpenTimeout      = 30 * time.Second
+	maxRetries              = 2
+	baseBackoff             = 100 * time.Millisecond
+	maxBackoff              = time.Second
+)
+
+// idempotentMethods Tweet service calls that are safe to retry. UpdateFeed is not, its retries
+// are left to the outbox.
+var idempotentMethods = map[string]bool{
+	"/grpc.health.v1.Health/Check": true,
+}
+
+// TweetClient Client backed by one long-lived gRPC connection to the tweet service,
+// feed removals are published over NATS since the tweet service has no gRPC method for them.
+type TweetClient struct {
+	tracer    trace.Tracer
+	conn      *grpc.ClientConn
+	client    tweet.TweetServiceClient
+	health    healthpb.HealthClient
+	breaker   *resilience.Breaker
+	publisher *messaging.Publisher
+}
+
+// NewTweetClient Dials c.Address without blocking, the connection is established on first use
+// and reestablished after failures. Every call is bounded by c.Timeout and rejected right away
+// while the tweet circuit breaker is open, idempotent calls are retried with jitter.
+func NewTweetClient(tracer trace.Tracer, c config.Tweet, tlsConfig config.TLS, publisher *messaging.Publisher) (*TweetClient, error) {
+	creds := credentials.NewTLS(tls.GetgRPCClientTLSConfig(tlsConfig))
+
+	policy := resilience.Policy{
+		Timeout:     c.Timeout,
+		MaxRetries:  maxRetries,
+		BaseBackoff: baseBackoff,
+		MaxBackoff:  maxBackoff,
+		Idempotent:  idempotentMethods,
+	}
+	breaker := resilience.NewBreaker("tweet", breakerFailureThreshold, breakerOpenTimeout)
+
+	conn, err := grpc.Dial(
+		c.Address,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithChainUnaryInterceptor(
+			otelgrpc.UnaryClientInterceptor(),
+			resilience.UnaryClientInterceptor(breaker, policy),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TweetClient{
+		tracer:    tracer,
+		conn:      conn,
+		client:    tweet.NewTweetServiceClient(conn),
+		health:    healthpb.NewHealthClient(conn),
+		breaker:   breaker,
+		publisher: publisher,
+	}, nil
+}
+
+// Ping Checks that the tweet service is serving with the standard gRPC health check. A tweet
+// service without the health service still counts as reachable once it answers.
+func (c *TweetClient) Ping(ctx context.Context) error {
+	clientCtx, span := c.tracer.Start(ctx, "TweetClient.Ping")
+	defer span.End()
+
+	resp, err := c.health.Check(clientCtx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) == grpccodes.Unimplemented {
+		return nil
+	}
+	if err == nil && resp.Status != healthpb.HealthCheckResponse_SERVING {
+		err = fmt.Errorf("tweet service is %s", resp.Status)
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+// BreakerState Returns the state of the tweet circuit breaker.
+func (c *TweetClient) BreakerState() resilience.State {
+	return c.breaker.State()
+}
+
+func (c *TweetClient) UpdateFeed(ctx context.Context, from string, to string) error {
+	clientCtx, span := c.tracer.Start(ctx, "TweetClient.UpdateFeed")
+	defer span.End()
+
+	clientCtx = metadata.AppendToOutgoingContext(clientCtx, "authUsername", from)
+	_, err := c.client.UpdateFeed(clientCtx, &tweet.Request{
+		From: from,
+		To:   to,
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (c *TweetClient) RemoveFromFeed(ctx context.Context, from string, to string) error {
+	clientCtx, span := c.tracer.Start(ctx, "TweetClient.RemoveFromFeed")
+	defer span.End()
+
+	err := c.publisher.Publish(clientCtx, messaging.FEED_REMOVE, messaging.FeedRemoval{
+		From: from,
+		To:   to,
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (c *TweetClient) Close() error {
+	return c.conn.Close()
+}
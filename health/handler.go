@@ -0,0 +1,22 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Liveness Responds ok as long as the process serves HTTP.
+func Liveness(w http.ResponseWriter, _ *http.Request) {
+	w.Write([]byte("ok"))
+}
+
+// Readiness Responds with the result of the checks, with status 503 when not ready.
+func (c *Checker) Readiness(w http.ResponseWriter, req *http.Request) {
+	result := c.Check(req.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if !result.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,152 @@
+package health
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	checkTimeout = 2 * time.Second
+	// grpcInterval How often the gRPC health service is updated from the checks.
+	grpcInterval = 10 * time.Second
+)
+
+// CheckFunc Returns an error when the dependency it checks is unavailable.
+type CheckFunc func(ctx context.Context) error
+
+// DetailFunc Returns the current value of a detail reported next to the checks.
+type DetailFunc func() string
+
+type check struct {
+	name     string
+	fn       CheckFunc
+	optional bool
+}
+
+// Result Status is ok or unavailable, Checks holds ok or the error of every check and Details
+// the value of every detail.
+type Result struct {
+	Status  string            `json:"status"`
+	Checks  map[string]string `json:"checks"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+func (r Result) Ready() bool {
+	return r.Status == "ok"
+}
+
+// Checker Readiness of the service, it is ready when every required check passes.
+type Checker struct {
+	tracer  trace.Tracer
+	checks  []check
+	details map[string]DetailFunc
+}
+
+func NewChecker(tracer trace.Tracer) *Checker {
+	return &Checker{
+		tracer:  tracer,
+		details: map[string]DetailFunc{},
+	}
+}
+
+// Add Adds a check that has to pass for the service to be ready.
+func (c *Checker) Add(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, fn: fn})
+}
+
+// AddOptional Adds a check that is reported, but doesn't affect readiness.
+func (c *Checker) AddOptional(name string, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, fn: fn, optional: true})
+}
+
+// AddDetail Adds a detail that is reported with the checks, like the state of a circuit breaker,
+// but doesn't affect readiness.
+func (c *Checker) AddDetail(name string, fn DetailFunc) {
+	c.details[name] = fn
+}
+
+// Check Runs all checks concurrently, each bounded by checkTimeout.
+func (c *Checker) Check(ctx context.Context) Result {
+	checkCtx, span := c.tracer.Start(ctx, "Checker.Check")
+	defer span.End()
+
+	result := Result{
+		Status: "ok",
+		Checks: make(map[string]string, len(c.checks)),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, ch := range c.checks {
+		wg.Add(1)
+		go func(ch check) {
+			defer wg.Done()
+			timeoutCtx, cancel := context.WithTimeout(checkCtx, checkTimeout)
+			defer cancel()
+			err := ch.fn(timeoutCtx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				result.Checks[ch.name] = "ok"
+				return
+			}
+			result.Checks[ch.name] = err.Error()
+			if !ch.optional {
+				result.Status = "unavailable"
+			}
+		}(ch)
+	}
+	wg.Wait()
+
+	if len(c.details) > 0 {
+		result.Details = make(map[string]string, len(c.details))
+		for name, fn := range c.details {
+			result.Details[name] = fn()
+		}
+	}
+
+	if !result.Ready() {
+		span.SetStatus(codes.Error, "not ready")
+	}
+	return result
+}
+
+// ServeGRPC Keeps the status of services in the gRPC health server in line with the checks
+// until ctx is done, then marks them not serving.
+func (c *Checker) ServeGRPC(ctx context.Context, server *health.Server, services ...string) {
+	services = append([]string{""}, services...)
+	setStatus := func(status healthpb.HealthCheckResponse_ServingStatus) {
+		for _, service := range services {
+			server.SetServingStatus(service, status)
+		}
+	}
+
+	ticker := time.NewTicker(grpcInterval)
+	defer ticker.Stop()
+	ready := false
+	for {
+		result := c.Check(ctx)
+		if result.Ready() != ready {
+			ready = result.Ready()
+			log.Printf("readiness changed to %s: %v", result.Status, result.Checks)
+		}
+		if ready {
+			setStatus(healthpb.HealthCheckResponse_SERVING)
+		} else {
+			setStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+		}
+
+		select {
+		case <-ctx.Done():
+			setStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("connection refused") }
+
+	type namedCheck struct {
+		name     string
+		fn       CheckFunc
+		optional bool
+	}
+	tests := []struct {
+		name   string
+		checks []namedCheck
+		status string
+		want   map[string]string
+	}{
+		{
+			name:   "no checks",
+			status: "ok",
+			want:   map[string]string{},
+		},
+		{
+			name:   "all pass",
+			checks: []namedCheck{{name: "neo4j", fn: ok}, {name: "tweet", fn: ok, optional: true}},
+			status: "ok",
+			want:   map[string]string{"neo4j": "ok", "tweet": "ok"},
+		},
+		{
+			name:   "required fails",
+			checks: []namedCheck{{name: "neo4j", fn: down}, {name: "nats", fn: ok}},
+			status: "unavailable",
+			want:   map[string]string{"neo4j": "connection refused", "nats": "ok"},
+		},
+		{
+			name:   "optional fails",
+			checks: []namedCheck{{name: "neo4j", fn: ok}, {name: "tweet", fn: down, optional: true}},
+			status: "ok",
+			want:   map[string]string{"neo4j": "ok", "tweet": "connection refused"},
+		},
+		{
+			name: "required hangs",
+			checks: []namedCheck{{name: "neo4j", fn: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}}},
+			status: "unavailable",
+			want:   map[string]string{"neo4j": context.DeadlineExceeded.Error()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(trace.NewNoopTracerProvider().Tracer("test"))
+			for _, ch := range tt.checks {
+				if ch.optional {
+					c.AddOptional(ch.name, ch.fn)
+				} else {
+					c.Add(ch.name, ch.fn)
+				}
+			}
+
+			result := c.Check(context.Background())
+			if result.Status != tt.status || result.Ready() != (tt.status == "ok") {
+				t.Errorf("got status %s, want %s", result.Status, tt.status)
+			}
+			if len(result.Checks) != len(tt.want) {
+				t.Fatalf("got checks %v, want %v", result.Checks, tt.want)
+			}
+			for name, want := range tt.want {
+				if result.Checks[name] != want {
+					t.Errorf("got %s %q, want %q", name, result.Checks[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{name: "ready", code: http.StatusOK},
+		{name: "not ready", err: errors.New("connection refused"), code: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(trace.NewNoopTracerProvider().Tracer("test"))
+			c.Add("neo4j", func(context.Context) error { return tt.err })
+
+			w := httptest.NewRecorder()
+			c.Readiness(w, httptest.NewRequest("GET", "/readyz", nil))
+			if w.Code != tt.code {
+				t.Errorf("got status %d, want %d", w.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestReadinessDetails(t *testing.T) {
+	c := NewChecker(trace.NewNoopTracerProvider().Tracer("test"))
+	c.AddOptional("tweet", func(context.Context) error { return errors.New("connection refused") })
+	state := "closed"
+	c.AddDetail("tweet_circuit_breaker", func() string { return state })
+
+	for _, want := range []string{"closed", "open"} {
+		state = want
+		w := httptest.NewRecorder()
+		c.Readiness(w, httptest.NewRequest("GET", "/readyz", nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("got status %d, details must not affect readiness", w.Code)
+		}
+
+		var result Result
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		if got := result.Details["tweet_circuit_breaker"]; got != want {
+			t.Errorf("got breaker detail %q, want %q", got, want)
+		}
+		if got := result.Checks["tweet"]; got != "connection refused" {
+			t.Errorf("got tweet check %q", got)
+		}
+	}
+}
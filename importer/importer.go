@@ -0,0 +1,168 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"social-graph/model"
+	"social-graph/repository"
+)
+
+const (
+	// BatchSize Number of rows validated and written in one repository transaction.
+	BatchSize = 500
+	// maxReportedErrors Rows failing beyond this are only counted.
+	maxReportedErrors = 1000
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported import format, expected csv or ndjson")
+
+type RowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// Report Follows and Requests count the rows saved, Skipped the rows that were already followed
+// or requested. Error is set when the import was aborted, the counts cover the rows before it.
+type Report struct {
+	DryRun   bool       `json:"dryRun"`
+	Rows     int        `json:"rows"`
+	Follows  int        `json:"follows"`
+	Requests int        `json:"requests"`
+	Skipped  int        `json:"skipped"`
+	Failed   int        `json:"failed"`
+	Errors   []RowError `json:"errors"`
+	Error    string     `json:"error,omitempty"`
+}
+
+func (r *Report) fail(line int, err error) {
+	r.Failed++
+	if len(r.Errors) < maxReportedErrors {
+		r.Errors = append(r.Errors, RowError{Line: line, Error: err.Error()})
+	}
+}
+
+// Import Reads from/to pairs in csv or ndjson format and saves them in batches. Follows of
+// private users are saved as follow requests. Rows that can't be imported are reported and
+// skipped, when dryRun is set nothing is saved. Batches saved before an error stay saved, and
+// are counted in the returned report.
+func Import(ctx context.Context, repo repository.SocialGraphRepository, r io.Reader, format string, dryRun bool) (Report, error) {
+	report, err := importRows(ctx, repo, r, format, dryRun)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return report, err
+}
+
+func importRows(ctx context.Context, repo repository.SocialGraphRepository, r io.Reader, format string, dryRun bool) (Report, error) {
+	report := Report{DryRun: dryRun, Errors: []RowError{}}
+
+	var rows rowReader
+	switch format {
+	case "csv":
+		rows = newCSVReader(r)
+	case "ndjson":
+		rows = newNDJSONReader(r)
+	default:
+		return report, ErrUnsupportedFormat
+	}
+
+	batch := make([]Row, 0, BatchSize)
+	// line of every follow in the import, duplicates can be in different batches
+	seen := make(map[model.Follow]int)
+	for {
+		row, err := rows.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+
+		report.Rows++
+		batch = append(batch, row)
+		if len(batch) == BatchSize {
+			if err := importBatch(ctx, repo, batch, seen, &report); err != nil {
+				return report, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	return report, importBatch(ctx, repo, batch, seen, &report)
+}
+
+func importBatch(ctx context.Context, repo repository.SocialGraphRepository, batch []Row, seen map[model.Follow]int, report *Report) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var usernames []string
+	for _, row := range batch {
+		if row.Err == nil {
+			usernames = append(usernames, row.From, row.To)
+		}
+	}
+	found, err := repo.GetUsers(ctx, usernames)
+	if err != nil {
+		return err
+	}
+	users := make(map[string]model.User, len(found))
+	for _, user := range found {
+		users[user.Username] = user
+	}
+
+	var follows []model.Follow
+	for _, row := range batch {
+		if row.Err != nil {
+			report.fail(row.Line, row.Err)
+			continue
+		}
+		if row.From == "" || row.To == "" {
+			report.fail(row.Line, errors.New("from and to are required"))
+			continue
+		}
+		if row.From == row.To {
+			report.fail(row.Line, errors.New("user can't follow itself"))
+			continue
+		}
+		if _, ok := users[row.From]; !ok {
+			report.fail(row.Line, fmt.Errorf("user %s does not exist", row.From))
+			continue
+		}
+		to, ok := users[row.To]
+		if !ok {
+			report.fail(row.Line, fmt.Errorf("user %s does not exist", row.To))
+			continue
+		}
+
+		follow := model.Follow{From: row.From, To: row.To, Request: to.IsPrivate}
+		if line, ok := seen[follow]; ok {
+			report.fail(row.Line, fmt.Errorf("duplicate of line %d", line))
+			continue
+		}
+		seen[follow] = row.Line
+
+		follows = append(follows, follow)
+	}
+
+	if len(follows) == 0 {
+		return nil
+	}
+	saved, err := repo.SaveFollows(ctx, follows, report.DryRun)
+	if err != nil {
+		return err
+	}
+	for i, follow := range follows {
+		switch {
+		case !saved[i]:
+			report.Skipped++
+		case follow.Request:
+			report.Requests++
+		default:
+			report.Follows++
+		}
+	}
+	return nil
+}
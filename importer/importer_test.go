@@ -0,0 +1,265 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"social-graph/model"
+	"social-graph/repository"
+	"strings"
+	"testing"
+)
+
+func readRows(t *testing.T, rows rowReader) []Row {
+	var result []Row
+	for {
+		row, err := rows.next()
+		if err == io.EOF {
+			return result
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, row)
+	}
+}
+
+func TestReaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		want   []Row
+		errs   []int
+	}{
+		{
+			name:   "csv with header",
+			format: "csv",
+			input:  "from,to\nalice,bob\n bob , carol\n",
+			want:   []Row{{Line: 2, From: "alice", To: "bob"}, {Line: 3, From: "bob", To: "carol"}},
+		},
+		{
+			name:   "csv without header",
+			format: "csv",
+			input:  "alice,bob\n",
+			want:   []Row{{Line: 1, From: "alice", To: "bob"}},
+		},
+		{
+			name:   "csv wrong field count",
+			format: "csv",
+			input:  "alice,bob\nalice\nalice,bob,carol\n",
+			want:   []Row{{Line: 1, From: "alice", To: "bob"}, {Line: 2}, {Line: 3}},
+			errs:   []int{2, 3},
+		},
+		{
+			name:   "csv bare quote",
+			format: "csv",
+			input:  "alice,bob\na\"lice,bob\n",
+			want:   []Row{{Line: 1, From: "alice", To: "bob"}, {Line: 2}},
+			errs:   []int{2},
+		},
+		{
+			name:   "ndjson",
+			format: "ndjson",
+			input:  "{\"from\":\"alice\",\"to\":\"bob\"}\n\n{\"from\":\" bob\",\"to\":\"carol \"}\n",
+			want:   []Row{{Line: 1, From: "alice", To: "bob"}, {Line: 3, From: "bob", To: "carol"}},
+		},
+		{
+			name:   "ndjson invalid line",
+			format: "ndjson",
+			input:  "{\"from\":\"alice\"\n{\"from\":\"alice\",\"to\":\"bob\"}\n",
+			want:   []Row{{Line: 1}, {Line: 2, From: "alice", To: "bob"}},
+			errs:   []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rows rowReader
+			if tt.format == "csv" {
+				rows = newCSVReader(strings.NewReader(tt.input))
+			} else {
+				rows = newNDJSONReader(strings.NewReader(tt.input))
+			}
+
+			got := readRows(t, rows)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got rows %+v, want %+v", got, tt.want)
+			}
+			var errs []int
+			for i, row := range got {
+				if row.Err != nil {
+					errs = append(errs, row.Line)
+					row.Err = nil
+				}
+				if row != tt.want[i] {
+					t.Errorf("got row %+v, want %+v", row, tt.want[i])
+				}
+			}
+			if fmt.Sprint(errs) != fmt.Sprint(tt.errs) {
+				t.Errorf("got errors on lines %v, want %v", errs, tt.errs)
+			}
+		})
+	}
+}
+
+type importRepository struct {
+	repository.SocialGraphRepository
+	users map[string]model.User
+	// existing Follows the database already has, SaveFollows skips them.
+	existing map[model.Follow]bool
+	saved    []model.Follow
+	batches  int
+	// failBatch Number of the batch SaveFollows fails, starting at 1.
+	failBatch int
+}
+
+func (r *importRepository) GetUsers(_ context.Context, usernames []string) ([]model.User, error) {
+	var users []model.User
+	for _, username := range usernames {
+		if user, ok := r.users[username]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *importRepository) SaveFollows(_ context.Context, follows []model.Follow, dryRun bool) ([]bool, error) {
+	r.batches++
+	if r.batches == r.failBatch {
+		return nil, errors.New("database is down")
+	}
+	saved := make([]bool, len(follows))
+	for i, follow := range follows {
+		if r.existing[follow] {
+			continue
+		}
+		saved[i] = true
+		if !dryRun {
+			r.existing[follow] = true
+			r.saved = append(r.saved, follow)
+		}
+	}
+	return saved, nil
+}
+
+func newImportRepository() *importRepository {
+	return &importRepository{
+		users: map[string]model.User{
+			"alice": {Username: "alice"},
+			"bob":   {Username: "bob"},
+			"carol": {Username: "carol", IsPrivate: true},
+		},
+		existing: map[model.Follow]bool{{From: "bob", To: "alice"}: true},
+	}
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		dryRun bool
+		want   Report
+		saved  int
+	}{
+		{
+			name:  "follows and requests",
+			input: "from,to\nalice,bob\nalice,carol\n",
+			want:  Report{Rows: 2, Follows: 1, Requests: 1},
+			saved: 2,
+		},
+		{
+			name:  "existing follow",
+			input: "bob,alice\nbob,carol\n",
+			want:  Report{Rows: 2, Requests: 1, Skipped: 1},
+			saved: 1,
+		},
+		{
+			name:  "invalid rows",
+			input: "alice,alice\nalice,dave\n,bob\nalice,bob\n",
+			want:  Report{Rows: 4, Follows: 1, Failed: 3},
+			saved: 1,
+		},
+		{
+			name:  "duplicate",
+			input: "alice,bob\nalice,bob\n",
+			want:  Report{Rows: 2, Follows: 1, Failed: 1},
+			saved: 1,
+		},
+		{
+			name:   "dry run",
+			input:  "alice,bob\nbob,alice\n",
+			dryRun: true,
+			want:   Report{DryRun: true, Rows: 2, Follows: 1, Skipped: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newImportRepository()
+			report, err := Import(context.Background(), repo, strings.NewReader(tt.input), "csv", tt.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(report.Errors) != report.Failed {
+				t.Errorf("got %d errors for %d failed rows", len(report.Errors), report.Failed)
+			}
+			report.Errors = nil
+			if fmt.Sprintf("%+v", report) != fmt.Sprintf("%+v", tt.want) {
+				t.Errorf("got report %+v, want %+v", report, tt.want)
+			}
+			if len(repo.saved) != tt.saved {
+				t.Errorf("saved %v, want %d follows", repo.saved, tt.saved)
+			}
+		})
+	}
+}
+
+func TestImportAcrossBatches(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < BatchSize; i++ {
+		input.WriteString("alice,bob\n")
+	}
+	input.WriteString("alice,bob\nbob,carol\n")
+
+	repo := newImportRepository()
+	report, err := Import(context.Background(), repo, strings.NewReader(input.String()), "csv", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Follows != 1 || report.Requests != 1 || report.Failed != BatchSize {
+		t.Errorf("got report %+v", report)
+	}
+	if report.Errors[len(report.Errors)-1].Error != "duplicate of line 1" {
+		t.Errorf("got last error %+v", report.Errors[len(report.Errors)-1])
+	}
+}
+
+func TestImportKeepsReportOfSavedBatches(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < BatchSize; i++ {
+		input.WriteString("alice,bob\n")
+	}
+	input.WriteString("bob,carol\n")
+
+	repo := newImportRepository()
+	repo.failBatch = 2
+	report, err := Import(context.Background(), repo, strings.NewReader(input.String()), "csv", false)
+	if err == nil {
+		t.Fatal("the failed batch was not reported")
+	}
+	if report.Error != err.Error() {
+		t.Errorf("got report error %q, want %q", report.Error, err.Error())
+	}
+	if report.Rows != BatchSize+1 || report.Follows != 1 || report.Requests != 0 {
+		t.Errorf("got report %+v", report)
+	}
+}
+
+func TestImportUnsupportedFormat(t *testing.T) {
+	_, err := Import(context.Background(), newImportRepository(), strings.NewReader(""), "xml", false)
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("got %v, want %v", err, ErrUnsupportedFormat)
+	}
+}
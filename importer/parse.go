@@ -0,0 +1,96 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+const maxLineSize = 64 * 1024
+
+// Row A follow to import. Err is set when the row could not be parsed.
+type Row struct {
+	Line int
+	From string
+	To   string
+	Err  error
+}
+
+type rowReader interface {
+	// next Returns the next row, or io.EOF when there are no more rows.
+	next() (Row, error)
+}
+
+type csvReader struct {
+	r      *csv.Reader
+	header bool
+}
+
+func newCSVReader(r io.Reader) *csvReader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	return &csvReader{r: cr}
+}
+
+func (c *csvReader) next() (Row, error) {
+	record, err := c.r.Read()
+	if err == io.EOF {
+		return Row{}, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return Row{Line: parseErr.Line, Err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return Row{}, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+	if !c.header {
+		c.header = true
+		if len(record) == 2 && strings.EqualFold(record[0], "from") && strings.EqualFold(record[1], "to") {
+			return c.next()
+		}
+	}
+	if len(record) != 2 {
+		return Row{Line: line, Err: errors.New("expected 2 fields: from,to")}, nil
+	}
+	return Row{Line: line, From: strings.TrimSpace(record[0]), To: strings.TrimSpace(record[1])}, nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, maxLineSize), maxLineSize)
+	return &ndjsonReader{scanner: scanner}
+}
+
+func (n *ndjsonReader) next() (Row, error) {
+	for n.scanner.Scan() {
+		n.line++
+		if len(strings.TrimSpace(n.scanner.Text())) == 0 {
+			continue
+		}
+
+		var v struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		if err := json.Unmarshal(n.scanner.Bytes(), &v); err != nil {
+			return Row{Line: n.line, Err: err}, nil
+		}
+		return Row{Line: n.line, From: strings.TrimSpace(v.From), To: strings.TrimSpace(v.To)}, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return Row{}, err
+	}
+	return Row{}, io.EOF
+}
@@ -0,0 +1,87 @@
+package interceptor
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"social-graph/model"
+)
+
+// AUTH_USERNAME Metadata key of the user a call is made on behalf of.
+const AUTH_USERNAME = "authUsername"
+
+// AuthUnaryServerInterceptor Puts the caller from the authUsername metadata into the context as
+// a model.AuthUser, under the same key as jwt.ExtractJWTUserMiddleware. Calls without it are
+// rejected with Unauthenticated, unless their method is one of publicMethods.
+func AuthUnaryServerInterceptor(tracer trace.Tracer, publicMethods ...string) grpc.UnaryServerInterceptor {
+	public := toSet(publicMethods)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		authCtx, err := authenticate(ctx, tracer, info.FullMethod, public)
+		if err != nil {
+			return nil, err
+		}
+		return handler(authCtx, req)
+	}
+}
+
+// AuthStreamServerInterceptor Like AuthUnaryServerInterceptor for streams, the handler gets a
+// stream whose context carries the caller.
+func AuthStreamServerInterceptor(tracer trace.Tracer, publicMethods ...string) grpc.StreamServerInterceptor {
+	public := toSet(publicMethods)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		authCtx, err := authenticate(ss.Context(), tracer, info.FullMethod, public)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: authCtx})
+	}
+}
+
+// GetAuthUser Returns the caller set by the auth interceptors, ok is false for anonymous calls
+// of public methods.
+func GetAuthUser(ctx context.Context) (authUser model.AuthUser, ok bool) {
+	authUser, ok = ctx.Value("authUser").(model.AuthUser)
+	return authUser, ok
+}
+
+func authenticate(ctx context.Context, tracer trace.Tracer, method string, public map[string]bool) (context.Context, error) {
+	_, span := tracer.Start(ctx, "AuthInterceptor.authenticate")
+	defer span.End()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(AUTH_USERNAME)
+	switch {
+	case len(values) == 0 && public[method]:
+		return ctx, nil
+	case len(values) == 0:
+		span.SetStatus(codes.Error, "missing "+AUTH_USERNAME)
+		return nil, status.Error(grpccodes.Unauthenticated, "missing "+AUTH_USERNAME+" metadata")
+	case len(values) > 1 || values[0] == "":
+		span.SetStatus(codes.Error, "invalid "+AUTH_USERNAME)
+		return nil, status.Error(grpccodes.InvalidArgument, AUTH_USERNAME+" metadata must be a single username")
+	}
+
+	return context.WithValue(ctx, "authUser", model.AuthUser{Username: values[0]}), nil
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// serverStream Replaces the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
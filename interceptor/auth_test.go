@@ -0,0 +1,89 @@
+package interceptor
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"social-graph/model"
+	"testing"
+)
+
+// testStream A grpc.ServerStream of which only the context is used.
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestAuthInterceptors(t *testing.T) {
+	const method = "/socialgraph.v1.SocialGraphService/GetFollowers"
+
+	tests := []struct {
+		name      string
+		md        metadata.MD
+		public    bool
+		code      grpccodes.Code
+		username  string
+		anonymous bool
+	}{
+		{name: "authenticated", md: metadata.Pairs(AUTH_USERNAME, "alice"), username: "alice"},
+		{name: "authenticated public method", md: metadata.Pairs(AUTH_USERNAME, "alice"), public: true, username: "alice"},
+		{name: "anonymous public method", public: true, anonymous: true},
+		{name: "missing username", code: grpccodes.Unauthenticated},
+		{name: "empty username", md: metadata.Pairs(AUTH_USERNAME, ""), code: grpccodes.InvalidArgument},
+		{name: "several usernames", md: metadata.Pairs(AUTH_USERNAME, "alice", AUTH_USERNAME, "bob"), code: grpccodes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracer := trace.NewNoopTracerProvider().Tracer("test")
+			var public []string
+			if tt.public {
+				public = append(public, method)
+			}
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+
+			check := func(ctx context.Context) {
+				user, ok := GetAuthUser(ctx)
+				if ok == tt.anonymous || user.Username != tt.username {
+					t.Errorf("got caller %+v, %t, want %q", user, ok, tt.username)
+				}
+			}
+
+			called := false
+			_, err := AuthUnaryServerInterceptor(tracer, public...)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				check(ctx)
+				return nil, nil
+			})
+			if status.Code(err) != tt.code || called != (tt.code == grpccodes.OK) {
+				t.Errorf("unary: got %v, handler called %t, want %s", err, called, tt.code)
+			}
+
+			called = false
+			err = AuthStreamServerInterceptor(tracer, public...)(nil, &testStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: method}, func(srv interface{}, ss grpc.ServerStream) error {
+				called = true
+				check(ss.Context())
+				return nil
+			})
+			if status.Code(err) != tt.code || called != (tt.code == grpccodes.OK) {
+				t.Errorf("stream: got %v, handler called %t, want %s", err, called, tt.code)
+			}
+		})
+	}
+}
+
+func TestGetAuthUserWithoutCaller(t *testing.T) {
+	if user, ok := GetAuthUser(context.Background()); ok || user != (model.AuthUser{}) {
+		t.Errorf("got caller %+v", user)
+	}
+}
@@ -0,0 +1,161 @@
+package interceptor
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"expvar"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+//go:embed default_policy.json
+var defaultPolicy []byte
+
+var deniedCalls = expvar.NewMap("grpc_denied_calls")
+
+// Policy Allowed gRPC methods per client certificate identity, the common name or a DNS name
+// of the certificate. A method is a full method name, /package.Service/* for all methods of a
+// service, or * for all methods.
+type Policy struct {
+	Identities map[string][]string `json:"identities"`
+}
+
+// LoadPolicy Reads the policy from the JSON file at path, or returns the built-in policy when
+// path is empty. The built-in policy allows the tweet and profile services the methods they
+// call, and the gateway, calling on behalf of users, all socialgraph.v1 methods.
+func LoadPolicy(path string) (*Policy, error) {
+	data := defaultPolicy
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var policy Policy
+	err := json.Unmarshal(data, &policy)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Allows Whether any of identities may call method.
+func (p *Policy) Allows(identities []string, method string) bool {
+	service := method[:strings.LastIndex(method, "/")+1] + "*"
+	for _, identity := range identities {
+		for _, allowed := range p.Identities[identity] {
+			if allowed == "*" || allowed == method || allowed == service {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AuthorizationUnaryServerInterceptor Rejects calls the policy doesn't allow for the verified
+// client certificate with PermissionDenied, and writes an audit record of each.
+func AuthorizationUnaryServerInterceptor(tracer trace.Tracer, policy *Policy, exemptMethods ...string) grpc.UnaryServerInterceptor {
+	exempt := toSet(exemptMethods)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !exempt[info.FullMethod] {
+			err := authorize(ctx, tracer, policy, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+func AuthorizationStreamServerInterceptor(tracer trace.Tracer, policy *Policy, exemptMethods ...string) grpc.StreamServerInterceptor {
+	exempt := toSet(exemptMethods)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !exempt[info.FullMethod] {
+			err := authorize(ss.Context(), tracer, policy, info.FullMethod)
+			if err != nil {
+				return err
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+func authorize(ctx context.Context, tracer trace.Tracer, policy *Policy, method string) error {
+	_, span := tracer.Start(ctx, "AuthorizationInterceptor.authorize")
+	defer span.End()
+
+	identities, address := peerIdentities(ctx)
+	if policy.Allows(identities, method) {
+		return nil
+	}
+
+	span.SetStatus(codes.Error, "permission denied")
+	deniedCalls.Add(method, 1)
+	audit(ctx, method, identities, address)
+	return status.Errorf(grpccodes.PermissionDenied, "%s is not allowed to call %s", strings.Join(identities, ", "), method)
+}
+
+// peerIdentities Returns the common name and DNS names of the verified client certificate,
+// and the address of the peer.
+func peerIdentities(ctx context.Context) ([]string, string) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, ""
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil, p.Addr.String()
+	}
+
+	cert := tlsInfo.State.VerifiedChains[0][0]
+	var identities []string
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	for _, name := range cert.DNSNames {
+		if name != cert.Subject.CommonName {
+			identities = append(identities, name)
+		}
+	}
+	return identities, p.Addr.String()
+}
+
+type auditRecord struct {
+	Time         time.Time `json:"time"`
+	Event        string    `json:"event"`
+	Method       string    `json:"method"`
+	Identities   []string  `json:"identities"`
+	Peer         string    `json:"peer"`
+	AuthUsername string    `json:"authUsername,omitempty"`
+}
+
+func audit(ctx context.Context, method string, identities []string, address string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	record := auditRecord{
+		Time:         time.Now().UTC(),
+		Event:        "grpc.permission_denied",
+		Method:       method,
+		Identities:   identities,
+		Peer:         address,
+		AuthUsername: strings.Join(md.Get(AUTH_USERNAME), ","),
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	log.Printf("audit: %s", data)
+}
@@ -0,0 +1,104 @@
+package interceptor
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicyAllows(t *testing.T) {
+	policy := &Policy{Identities: map[string][]string{
+		"tweet":   {"/social_graph.SocialGraphService/CheckVisibility"},
+		"gateway": {"/socialgraph.v1.SocialGraphService/*"},
+		"admin":   {"*"},
+	}}
+
+	tests := []struct {
+		name       string
+		identities []string
+		method     string
+		allowed    bool
+	}{
+		{name: "method", identities: []string{"tweet"}, method: "/social_graph.SocialGraphService/CheckVisibility", allowed: true},
+		{name: "other method", identities: []string{"tweet"}, method: "/social_graph.SocialGraphService/GetMyFollowers"},
+		{name: "service", identities: []string{"gateway"}, method: "/socialgraph.v1.SocialGraphService/CreateFollow", allowed: true},
+		{name: "other service", identities: []string{"gateway"}, method: "/social_graph.SocialGraphService/CheckVisibility"},
+		{name: "all methods", identities: []string{"admin"}, method: "/grpc.health.v1.Health/Check", allowed: true},
+		{name: "any identity", identities: []string{"unknown", "tweet"}, method: "/social_graph.SocialGraphService/CheckVisibility", allowed: true},
+		{name: "unknown identity", identities: []string{"unknown"}, method: "/social_graph.SocialGraphService/CheckVisibility"},
+		{name: "no identity", method: "/social_graph.SocialGraphService/CheckVisibility"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Allows(tt.identities, tt.method); got != tt.allowed {
+				t.Errorf("got %t, want %t", got, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(valid, []byte(`{"identities": {"tweet": ["*"]}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"identities": ["tweet"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		err      bool
+		identity string
+		method   string
+	}{
+		{name: "built-in", identity: "gateway", method: "/socialgraph.v1.SocialGraphService/UpdatePrivacy"},
+		{name: "file", path: valid, identity: "tweet", method: "/socialgraph.v1.SocialGraphService/UpdatePrivacy"},
+		{name: "missing file", path: filepath.Join(dir, "missing.json"), err: true},
+		{name: "invalid file", path: invalid, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := LoadPolicy(tt.path)
+			if (err != nil) != tt.err {
+				t.Fatalf("got %v, want error %t", err, tt.err)
+			}
+			if err == nil && !policy.Allows([]string{tt.identity}, tt.method) {
+				t.Errorf("%s isn't allowed to call %s", tt.identity, tt.method)
+			}
+		})
+	}
+}
+
+func TestAuthorizationExemptMethods(t *testing.T) {
+	tracer := trace.NewNoopTracerProvider().Tracer("test")
+	const exempt = "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"
+	interceptor := AuthorizationStreamServerInterceptor(tracer, &Policy{}, exempt)
+
+	tests := []struct {
+		method string
+		code   grpccodes.Code
+	}{
+		{exempt, grpccodes.OK},
+		{"/socialgraph.v1.SocialGraphService/StreamFollowers", grpccodes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		err := interceptor(nil, &testStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: tt.method}, func(interface{}, grpc.ServerStream) error {
+			return nil
+		})
+		if status.Code(err) != tt.code {
+			t.Errorf("%s: got %v, want %s", tt.method, err, tt.code)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package interceptor
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"runtime/debug"
+)
+
+// RecoveryUnaryServerInterceptor Turns a panic of a handler into an Internal error instead of
+// crashing the server.
+func RecoveryUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer recoverTo(info.FullMethod, &err)
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamServerInterceptor Turns a panic of a stream handler into an Internal error ending
+// the stream.
+func RecoveryStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer recoverTo(info.FullMethod, &err)
+		return handler(srv, ss)
+	}
+}
+
+func recoverTo(method string, err *error) {
+	if p := recover(); p != nil {
+		log.Printf("%s panicked: %v\n%s", method, p, debug.Stack())
+		*err = status.Error(grpccodes.Internal, "internal error")
+	}
+}
@@ -0,0 +1,49 @@
+package interceptor
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestRecoveryInterceptors(t *testing.T) {
+	tests := []struct {
+		name  string
+		panic bool
+		code  grpccodes.Code
+	}{
+		{name: "no panic", code: grpccodes.NotFound},
+		{name: "panic", panic: true, code: grpccodes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handle := func() error {
+				if tt.panic {
+					panic("nil map")
+				}
+				return status.Error(grpccodes.NotFound, "no user")
+			}
+
+			_, err := RecoveryUnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test/Unary"}, func(context.Context, interface{}) (interface{}, error) {
+				return nil, handle()
+			})
+			if status.Code(err) != tt.code {
+				t.Errorf("unary: got %v, want %s", err, tt.code)
+			}
+
+			err = RecoveryStreamServerInterceptor()(nil, &testStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/test/Stream"}, func(interface{}, grpc.ServerStream) error {
+				return handle()
+			})
+			if status.Code(err) != tt.code {
+				t.Errorf("stream: got %v, want %s", err, tt.code)
+			}
+			// the panic value may hold internals, it isn't sent to the client
+			if tt.panic && status.Convert(err).Message() != "internal error" {
+				t.Errorf("got message %q", status.Convert(err).Message())
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"social-graph/repository"
+)
+
+// StatusUnaryServerInterceptor Converts errors returned by handlers that aren't already a status,
+// so handlers can return service and repository errors as they are.
+func StatusUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		return resp, toStatus(err)
+	}
+}
+
+// StatusStreamServerInterceptor Converts the error ending a stream like StatusUnaryServerInterceptor.
+func StatusStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return toStatus(handler(srv, ss))
+	}
+}
+
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	switch {
+	case errors.Is(err, repository.ErrUserNotFound):
+		return status.Error(grpccodes.NotFound, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(grpccodes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(grpccodes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(grpccodes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,48 @@
+package interceptor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"social-graph/repository"
+	"testing"
+)
+
+func TestStatusInterceptors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code grpccodes.Code
+	}{
+		{name: "ok", code: grpccodes.OK},
+		{name: "status", err: status.Error(grpccodes.InvalidArgument, "bad"), code: grpccodes.InvalidArgument},
+		{name: "user not found", err: fmt.Errorf("get user: %w", repository.ErrUserNotFound), code: grpccodes.NotFound},
+		{name: "canceled", err: context.Canceled, code: grpccodes.Canceled},
+		{name: "deadline exceeded", err: fmt.Errorf("query: %w", context.DeadlineExceeded), code: grpccodes.DeadlineExceeded},
+		{name: "other", err: errors.New("database is down"), code: grpccodes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := StatusUnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{}, func(context.Context, interface{}) (interface{}, error) {
+				return nil, tt.err
+			})
+			if status.Code(err) != tt.code {
+				t.Errorf("unary: got %v, want %s", err, tt.code)
+			}
+
+			err = StatusStreamServerInterceptor()(nil, &testStream{ctx: context.Background()}, &grpc.StreamServerInfo{}, func(interface{}, grpc.ServerStream) error {
+				return tt.err
+			})
+			if status.Code(err) != tt.code {
+				t.Errorf("stream: got %v, want %s", err, tt.code)
+			}
+			if tt.err != nil && status.Convert(err).Message() != status.Convert(tt.err).Message() {
+				t.Errorf("got message %q, want %q", status.Convert(err).Message(), tt.err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package lifecycle
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"net"
+	"net/http"
+)
+
+// HTTPServer Stops accepting connections on Stop and waits for in-flight requests. serve is
+// the ListenAndServe variant to run.
+func HTTPServer(name string, srv *http.Server, serve func() error) Component {
+	return Component{
+		Name: name,
+		Run: func() error {
+			err := serve()
+			if err == http.ErrServerClosed {
+				return nil
+			}
+			return err
+		},
+		Stop: srv.Shutdown,
+	}
+}
+
+// GRPCServer Stops accepting connections on Stop and waits for in-flight calls, or cancels
+// them once ctx is done.
+func GRPCServer(name string, srv *grpc.Server, lis net.Listener) Component {
+	return Component{
+		Name: name,
+		Run: func() error {
+			return srv.Serve(lis)
+		},
+		Stop: func(ctx context.Context) error {
+			stopped := make(chan struct{})
+			go func() {
+				srv.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+				return nil
+			case <-ctx.Done():
+				srv.Stop()
+				return ctx.Err()
+			}
+		},
+	}
+}
+
+// Worker Runs fn until Stop cancels its context, Stop waits for fn to return.
+func Worker(name string, fn func(ctx context.Context)) Component {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	return Component{
+		Name: name,
+		Run: func() error {
+			defer close(done)
+			fn(ctx)
+			return nil
+		},
+		Stop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return stopCtx.Err()
+			}
+		},
+	}
+}
+
+// Closer Releases a resource on Stop, like a connection pool.
+func Closer(name string, stop func(ctx context.Context) error) Component {
+	return Component{
+		Name: name,
+		Stop: stop,
+	}
+}
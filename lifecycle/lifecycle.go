@@ -0,0 +1,104 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Component Part of the service managed by a Manager. Run, if set, serves until Stop is called
+// or the component fails. Stop, if set, stops the component gracefully and gives up once ctx
+// is done.
+type Component struct {
+	Name string
+	Run  func() error
+	Stop func(ctx context.Context) error
+	// StopTimeout If set, Stop gets a deadline of its own instead of what is left of the shutdown
+	// timeout, so that it runs even when the components stopped before it used all of it.
+	StopTimeout time.Duration
+}
+
+// Manager Runs components in the order they were added and stops them in reverse order, so
+// a component is stopped before the components it depends on.
+type Manager struct {
+	shutdownTimeout time.Duration
+	components      []Component
+}
+
+func NewManager(shutdownTimeout time.Duration) *Manager {
+	return &Manager{
+		shutdownTimeout: shutdownTimeout,
+	}
+}
+
+func (m *Manager) Add(components ...Component) {
+	m.components = append(m.components, components...)
+}
+
+// Run Runs all components until SIGINT or SIGTERM is received, ctx is done or a component
+// fails, then stops them all within the shutdown timeout. Returns the error that caused the
+// shutdown, or the first error of stopping.
+func (m *Manager) Run(ctx context.Context) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	failed := make(chan error, len(m.components))
+	for _, c := range m.components {
+		if c.Run == nil {
+			continue
+		}
+		log.Printf("starting %s", c.Name)
+		go func(c Component) {
+			if err := c.Run(); err != nil {
+				failed <- errors.New(c.Name + ": " + err.Error())
+			}
+		}(c)
+	}
+
+	var cause error
+	select {
+	case sig := <-signals:
+		log.Printf("received %s, shutting down", sig)
+	case <-ctx.Done():
+		log.Println("shutting down")
+	case cause = <-failed:
+		log.Printf("shutting down after failure of %v", cause)
+	}
+
+	return m.stop(cause)
+}
+
+func (m *Manager) stop(cause error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+
+	for i := len(m.components) - 1; i >= 0; i-- {
+		c := m.components[i]
+		if c.Stop == nil {
+			continue
+		}
+		log.Printf("stopping %s", c.Name)
+		if err := m.stopComponent(ctx, c); err != nil {
+			log.Printf("failed to stop %s: %v", c.Name, err)
+			if cause == nil {
+				cause = errors.New(c.Name + ": " + err.Error())
+			}
+		}
+	}
+	log.Println("stopped")
+	return cause
+}
+
+func (m *Manager) stopComponent(ctx context.Context, c Component) error {
+	if c.StopTimeout == 0 {
+		return c.Stop(ctx)
+	}
+	stopCtx, cancel := context.WithTimeout(context.Background(), c.StopTimeout)
+	defer cancel()
+	return c.Stop(stopCtx)
+}
@@ -0,0 +1,144 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder Records the order components are stopped in.
+type recorder struct {
+	mu      sync.Mutex
+	stopped []string
+}
+
+func (r *recorder) closer(name string, err error) Component {
+	return Closer(name, func(context.Context) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.stopped = append(r.stopped, name)
+		return err
+	})
+}
+
+func TestManagerStopsInReverseOrder(t *testing.T) {
+	r := &recorder{}
+	m := NewManager(time.Second)
+	m.Add(r.closer("neo4j", nil), r.closer("nats", nil))
+	m.Add(Worker("outbox dispatcher", func(ctx context.Context) {
+		<-ctx.Done()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.stopped = append(r.stopped, "outbox dispatcher")
+	}))
+	m.Add(r.closer("grpc server", nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"grpc server", "outbox dispatcher", "nats", "neo4j"}
+	if fmt.Sprint(r.stopped) != fmt.Sprint(want) {
+		t.Errorf("stopped %v, want %v", r.stopped, want)
+	}
+}
+
+func TestManagerCause(t *testing.T) {
+	tests := []struct {
+		name    string
+		runErr  error
+		stopErr error
+		want    string
+	}{
+		{name: "clean shutdown"},
+		{name: "failed component", runErr: errors.New("address in use"), want: "http server: address in use"},
+		{name: "failed stop", stopErr: errors.New("timeout"), want: "neo4j: timeout"},
+		{name: "failure before failed stop", runErr: errors.New("address in use"), stopErr: errors.New("timeout"), want: "http server: address in use"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			m := NewManager(time.Second)
+			m.Add(r.closer("neo4j", tt.stopErr))
+			serving := make(chan struct{})
+			m.Add(Component{
+				Name: "http server",
+				Run: func() error {
+					if tt.runErr != nil {
+						return tt.runErr
+					}
+					<-serving
+					return nil
+				},
+				Stop: func(context.Context) error {
+					close(serving)
+					return nil
+				},
+			})
+
+			// without a failure the shutdown is started by ctx
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.runErr == nil {
+				cancel()
+			}
+			defer cancel()
+
+			got := ""
+			if err := m.Run(ctx); err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if len(r.stopped) != 1 {
+				t.Errorf("stopped %v after the failure", r.stopped)
+			}
+		})
+	}
+}
+
+func TestManagerStopTimeout(t *testing.T) {
+	const shutdownTimeout = 50 * time.Millisecond
+
+	var sharedErr, ownErr error
+	var ownDeadline time.Duration
+	m := NewManager(shutdownTimeout)
+	m.Add(Closer("neo4j", func(ctx context.Context) error {
+		sharedErr = ctx.Err()
+		return nil
+	}))
+	tracer := Closer("tracer", func(ctx context.Context) error {
+		ownErr = ctx.Err()
+		deadline, _ := ctx.Deadline()
+		ownDeadline = time.Until(deadline)
+		return nil
+	})
+	tracer.StopTimeout = time.Second
+	m.Add(tracer)
+	// uses up the shutdown timeout
+	m.Add(Closer("grpc server", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := m.Run(ctx)
+	if err == nil || err.Error() != "grpc server: "+context.DeadlineExceeded.Error() {
+		t.Errorf("got %v, want the grpc server to time out", err)
+	}
+
+	if sharedErr != context.DeadlineExceeded {
+		t.Errorf("neo4j got context error %v after the shutdown timeout, want %v", sharedErr, context.DeadlineExceeded)
+	}
+	if ownErr != nil || ownDeadline <= shutdownTimeout || ownDeadline > time.Second {
+		t.Errorf("tracer got context error %v and %v left, want its own deadline of %v", ownErr, ownDeadline, time.Second)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"github.com/FTN-TwitterClone/grpc-stubs/proto/social_graph"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -10,14 +11,24 @@ import (
 	"go.opentelemetry.io/otel/propagation"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 	"log"
 	"net"
 	"net/http"
 	"os"
-	"os/signal"
+	"social-graph/config"
 	"social-graph/controller"
 	"social-graph/controller/jwt"
+	"social-graph/feed"
+	"social-graph/health"
+	"social-graph/interceptor"
+	"social-graph/lifecycle"
+	"social-graph/messaging"
+	"social-graph/model"
+	"social-graph/outbox"
+	socialgraphv1 "social-graph/proto/socialgraph/v1"
 	"social-graph/repository/neo4jRepo"
 	"social-graph/saga"
 	"social-graph/service"
@@ -26,106 +37,218 @@ import (
 	"time"
 )
 
+// tracerFlushTimeout Bounds flushing the spans left on shutdown.
+const tracerFlushTimeout = 5 * time.Second
+
 func main() {
 	ctx := context.Background()
+
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	exp, err := tracing.NewExporter()
 	if err != nil {
 		log.Fatalf("failed to initialize exporter: %v", err)
 	}
 	// Create a new tracer provider with a batch span processor and the given exporter.
 	tp := tracing.NewTraceProvider(exp)
-	// Handle shutdown properly so nothing leaks.
-	defer func() { _ = tp.Shutdown(ctx) }()
 	otel.SetTracerProvider(tp)
 	// Finally, set the tracer that can be used for this package.
 	tracer := tp.Tracer("social-graph")
 	otel.SetTextMapPropagator(propagation.TraceContext{})
 
-	repositoryNeo4j, err := neo4jRepo.NewRepositoryNeo4j(tracer)
+	repositoryNeo4j, err := neo4jRepo.NewRepositoryNeo4j(tracer, cfg.Neo4j)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = repositoryNeo4j.CreateIndexes(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	natsConn, err := messaging.NewNATSConnection(cfg.NATS)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	deadLetters, err := saga.NewDeadLetterQueue(tracer, natsConn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	registerHandler, err := saga.NewRegisterUserHandler(tracer, natsConn, repositoryNeo4j, deadLetters)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	deleteHandler, err := saga.NewDeleteUserHandler(tracer, natsConn, repositoryNeo4j, deadLetters)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	queryResponder, err := messaging.NewQueryResponder(tracer, natsConn, repositoryNeo4j, cfg.NATS.QueryToken)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	_, err = saga.NewRegisterUserHandler(tracer, repositoryNeo4j)
+	publisher := messaging.NewPublisher(tracer, natsConn)
+	tweetClient, err := feed.NewTweetClient(tracer, cfg.Tweet, cfg.TLS, publisher)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	socialGraphService := service.NewSocialGraphService(repositoryNeo4j, tracer)
+	events := messaging.NewEventPublisher(tracer, publisher)
+	socialGraphService := service.NewSocialGraphService(repositoryNeo4j, tweetClient, events, tracer)
+
+	dispatcher := outbox.NewDispatcher(repositoryNeo4j, tracer)
+	dispatcher.Handle(model.OutboxFeedUpdate, socialGraphService.DeliverFeedUpdate)
+	dispatcher.Handle(model.OutboxFeedRemove, socialGraphService.DeliverFeedRemoval)
+
+	checker := health.NewChecker(tracer)
+	checker.Add("neo4j", repositoryNeo4j.Ping)
+	checker.Add("nats", func(context.Context) error {
+		return messaging.CheckConnection(natsConn)
+	})
+	// feed updates wait in the outbox while the tweet service is down, so it doesn't affect readiness
+	checker.AddOptional("tweet", tweetClient.Ping)
+	checker.AddDetail("tweet_circuit_breaker", func() string {
+		return tweetClient.BreakerState().String()
+	})
 
 	socialGraphController := controller.NewSocialGraphController(socialGraphService, tracer)
-	router := mux.NewRouter()
-	router.Use(
-		tracing.ExtractTraceInfoMiddleware,
-		jwt.ExtractJWTUserMiddleware(tracer),
-	)
+	root := mux.NewRouter()
+	root.Use(tracing.ExtractTraceInfoMiddleware)
+	root.HandleFunc("/healthz", health.Liveness).Methods("GET")
+	root.HandleFunc("/readyz", checker.Readiness).Methods("GET")
+
+	router := root.PathPrefix("/").Subrouter()
+	router.Use(jwt.ExtractJWTUserMiddleware(tracer, cfg.JWT.SecretKey))
 
 	router.HandleFunc("/follows/{username}", socialGraphController.CreateFollow).Methods("POST")
 	router.HandleFunc("/follows/{username}", socialGraphController.RemoveFollow).Methods("DELETE")
 	router.HandleFunc("/following/{username}", socialGraphController.GetFollowing).Methods("GET")
 	router.HandleFunc("/following/{username}/count", socialGraphController.GetNumberOfFollowing).Methods("GET")
 	router.HandleFunc("/followers/{username}", socialGraphController.GetFollowers).Methods("GET")
+	router.HandleFunc("/followers/{username}", socialGraphController.RemoveFollower).Methods("DELETE")
 	router.HandleFunc("/followers/{username}/count", socialGraphController.GetNumberOfFollowers).Methods("GET")
 	router.HandleFunc("/follows/{username}", socialGraphController.CheckIfFollowExists).Methods("GET")
 	router.HandleFunc("/follows-request/{username}", socialGraphController.CheckIfFollowRequestExists).Methods("GET")
 	router.HandleFunc("/follows-request", socialGraphController.GetAllFollowRequests).Methods("GET")
+	router.HandleFunc("/followers-growth", socialGraphController.GetFollowerGrowth).Methods("GET")
+	router.HandleFunc("/ego-network/{username}", socialGraphController.GetEgoNetwork).Methods("GET")
+	router.HandleFunc("/privacy", socialGraphController.UpdatePrivacy).Methods("PUT")
 	router.HandleFunc("/recommendations", socialGraphController.GetRecommendationsProfile).Methods("GET")
 	router.HandleFunc("/follows/{username}", socialGraphController.AcceptRejectFollowRequest).Methods("PATCH")
 
+	adminController := controller.NewAdminController(socialGraphService, deadLetters, tracer)
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(jwt.RequireRoleMiddleware(tracer, "ROLE_ADMIN"))
+
+	// expvar exposes the command line and memory stats along with the metrics
+	admin.Handle("/metrics", expvar.Handler()).Methods("GET")
+	admin.HandleFunc("/export", adminController.ExportGraph).Methods("GET")
+	admin.HandleFunc("/snapshot", adminController.SaveSnapshot).Methods("GET")
+	admin.HandleFunc("/snapshot", adminController.RestoreSnapshot).Methods("POST")
+	admin.HandleFunc("/import/follows", adminController.ImportFollows).Methods("POST")
+	admin.HandleFunc("/dead-letters", adminController.GetDeadLetters).Methods("GET")
+	admin.HandleFunc("/dead-letters/{sequence}/replay", adminController.ReplayDeadLetter).Methods("POST")
+
 	allowedHeaders := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"})
 	allowedMethods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "HEAD", "OPTIONS", "PATCH", "DELETE"})
 	allowedOrigins := handlers.AllowedOrigins([]string{"*"})
 
 	// start server
 	srv := &http.Server{
-		Addr:      "0.0.0.0:8000",
-		Handler:   handlers.CORS(allowedHeaders, allowedMethods, allowedOrigins)(router),
-		TLSConfig: tls.GetHTTPServerTLSConfig(),
+		Addr:      cfg.HTTP.Address,
+		Handler:   handlers.CORS(allowedHeaders, allowedMethods, allowedOrigins)(root),
+		TLSConfig: tls.GetHTTPServerTLSConfig(cfg.TLS),
 	}
 
-	go func() {
-		log.Println("server starting")
-
-		certFile := os.Getenv("CERT")
-		keyFile := os.Getenv("KEY")
+	lis, err := net.Listen("tcp", cfg.GRPC.Address)
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
 
-		if err := srv.ListenAndServeTLS(certFile, keyFile); err != nil {
-			if err != http.ErrServerClosed {
-				log.Fatal(err)
-			}
-		}
-	}()
+	creds := credentials.NewTLS(tls.GetgRPCServerTLSConfig(cfg.TLS))
 
-	lis, err := net.Listen("tcp", "0.0.0.0:9001")
+	// methods allowed per client certificate, the built-in policy unless a policy file is set
+	policy, err := interceptor.LoadPolicy(cfg.GRPC.PolicyFile)
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		log.Fatalf("invalid gRPC policy: %v", err)
 	}
 
-	creds := credentials.NewTLS(tls.GetgRPCServerTLSConfig())
+	// health checks and reflection are open to every client with a valid certificate, and aren't
+	// made on behalf of a user
+	exemptMethods := []string{
+		"/grpc.health.v1.Health/Check",
+		"/grpc.health.v1.Health/Watch",
+		"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+	}
+	publicMethods := append(append([]string{}, service.PublicMethodsV1...), exemptMethods...)
 
 	grpcServer := grpc.NewServer(
 		grpc.Creds(creds),
-		grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor()),
+		grpc.ChainUnaryInterceptor(
+			otelgrpc.UnaryServerInterceptor(),
+			interceptor.RecoveryUnaryServerInterceptor(),
+			interceptor.StatusUnaryServerInterceptor(),
+			interceptor.AuthorizationUnaryServerInterceptor(tracer, policy, exemptMethods...),
+			interceptor.AuthUnaryServerInterceptor(tracer, publicMethods...),
+		),
+		grpc.ChainStreamInterceptor(
+			otelgrpc.StreamServerInterceptor(),
+			interceptor.RecoveryStreamServerInterceptor(),
+			interceptor.StatusStreamServerInterceptor(),
+			interceptor.AuthorizationStreamServerInterceptor(tracer, policy, exemptMethods...),
+			interceptor.AuthStreamServerInterceptor(tracer, publicMethods...),
+		),
 	)
 
-	social_graph.RegisterSocialGraphServiceServer(grpcServer, service.NewgRPCSocialGraphService(tracer, repositoryNeo4j))
+	social_graph.RegisterSocialGraphServiceServer(grpcServer, service.NewgRPCSocialGraphService(tracer, repositoryNeo4j, socialGraphService))
+	socialgraphv1.RegisterSocialGraphServiceServer(grpcServer, service.NewgRPCSocialGraphServiceV1(tracer, socialGraphService))
+	healthServer := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
 	reflection.Register(grpcServer)
-	err = grpcServer.Serve(lis)
-	if err != nil {
-		return
-	}
-
-	sigCh := make(chan os.Signal)
-	signal.Notify(sigCh, os.Interrupt)
-	signal.Notify(sigCh, os.Kill)
 
-	sig := <-sigCh
-	log.Println("Received terminate, graceful shutdown", sig)
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	// the spans of the drained requests are flushed even when draining used up the shutdown timeout
+	tracerFlush := lifecycle.Closer("tracer", tp.Shutdown)
+	tracerFlush.StopTimeout = tracerFlushTimeout
+
+	// stopped in reverse order: servers, the outbox dispatcher and the NATS consumers first, so
+	// in-flight requests and commands can still use everything below them, then the tracer is
+	// flushed once nothing records spans anymore, then the connections are closed
+	manager := lifecycle.NewManager(cfg.ShutdownTimeout)
+	manager.Add(
+		lifecycle.Closer("neo4j", func(context.Context) error {
+			return repositoryNeo4j.Close()
+		}),
+		lifecycle.Closer("tweet client", func(context.Context) error {
+			return tweetClient.Close()
+		}),
+		// flushes pending publishes, the subscriptions are drained by then
+		lifecycle.Closer("nats", func(ctx context.Context) error {
+			return messaging.Drain(ctx, natsConn)
+		}),
+		tracerFlush,
+		lifecycle.Closer("register saga", registerHandler.Drain),
+		lifecycle.Closer("delete saga", deleteHandler.Drain),
+		lifecycle.Closer("query responder", queryResponder.Drain),
+		lifecycle.Worker("outbox dispatcher", dispatcher.Run),
+		lifecycle.GRPCServer("grpc server", grpcServer, lis),
+		lifecycle.HTTPServer("http server", srv, func() error {
+			return srv.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		}),
+		// stopped first, so clients see NOT_SERVING before the servers stop
+		lifecycle.Worker("grpc health", func(ctx context.Context) {
+			checker.ServeGRPC(ctx, healthServer, "social_graph.SocialGraphService", "socialgraph.v1.SocialGraphService")
+		}),
+	)
 
-	if srv.Shutdown(ctx) != nil {
-		log.Fatal("Cannot gracefully shutdown...")
+	err = manager.Run(ctx)
+	if err != nil {
+		log.Fatal(err)
 	}
-	log.Println("Server stopped")
 }
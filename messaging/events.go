@@ -0,0 +1,80 @@
+package messaging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+// Subjects of the social graph domain events. Every event is an Event envelope,
+// the JSON schema of all of them is in events.schema.json.
+const (
+	FOLLOW_CREATED          = "socialgraph.follow.created"
+	FOLLOW_DELETED          = "socialgraph.follow.deleted"
+	FOLLOW_REQUEST_CREATED  = "socialgraph.follow.request.created"
+	FOLLOW_REQUEST_ACCEPTED = "socialgraph.follow.request.accepted"
+	FOLLOW_REQUEST_REJECTED = "socialgraph.follow.request.rejected"
+	USER_PRIVACY_CHANGED    = "socialgraph.user.privacy_changed"
+)
+
+// EventVersion Version of the event envelope and payloads, incremented on breaking changes.
+const EventVersion = 1
+
+type Event struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	Version    int         `json:"version"`
+	OccurredAt time.Time   `json:"occurredAt"`
+	Data       interface{} `json:"data"`
+}
+
+// FollowEventData Payload of the follow and follow request events.
+type FollowEventData struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type PrivacyChangedEventData struct {
+	Username string `json:"username"`
+	Private  bool   `json:"private"`
+}
+
+type EventPublisher struct {
+	tracer    trace.Tracer
+	publisher *Publisher
+}
+
+func NewEventPublisher(tracer trace.Tracer, publisher *Publisher) *EventPublisher {
+	return &EventPublisher{
+		tracer:    tracer,
+		publisher: publisher,
+	}
+}
+
+// Publish Publishes data as an event of type eventType on the subject of the same name.
+func (p *EventPublisher) Publish(ctx context.Context, eventType string, data interface{}) error {
+	publishCtx, span := p.tracer.Start(ctx, "EventPublisher.Publish")
+	defer span.End()
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	err := p.publisher.Publish(publishCtx, eventType, Event{
+		ID:         hex.EncodeToString(id),
+		Type:       eventType,
+		Version:    EventVersion,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
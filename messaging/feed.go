@@ -0,0 +1,14 @@
+package messaging
+
+const (
+	// FEED_REMOVE Consumed by the tweet service, which removes the tweets of To from the feed of From.
+	// Published once per removed follow with a FeedRemoval payload, the JSON schema is in feed.schema.json.
+	FEED_REMOVE = "feed.remove"
+)
+
+// FeedRemoval Payload of FEED_REMOVE, From stopped following To or To was deleted.
+// Published at least once, so consumers must tolerate a repeated removal.
+type FeedRemoval struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
@@ -0,0 +1,16 @@
+package messaging
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFeedRemovalPayload(t *testing.T) {
+	data, err := json.Marshal(FeedRemoval{From: "alice", To: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"from":"alice","to":"bob"}`; string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
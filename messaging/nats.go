@@ -0,0 +1,121 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"social-graph/config"
+	"social-graph/tracing"
+	"time"
+)
+
+// drainPollInterval How often DrainSubscriptions checks whether the subscriptions are drained.
+const drainPollInterval = 10 * time.Millisecond
+
+func NewNATSConnection(c config.NATS) (*nats.Conn, error) {
+	url := fmt.Sprintf("nats://%s:%s", c.Host, c.Port)
+
+	return nats.Connect(url)
+}
+
+// Drain Unsubscribes all subscriptions of conn, waits for their handlers to finish and for
+// pending publishes to be flushed, then closes conn. It is closed right away once ctx is done.
+func Drain(ctx context.Context, conn *nats.Conn) error {
+	closed := make(chan struct{})
+	conn.SetClosedHandler(func(*nats.Conn) {
+		close(closed)
+	})
+
+	err := conn.Drain()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		conn.Close()
+		return ctx.Err()
+	}
+}
+
+// DrainSubscriptions Unsubscribes subs and waits for the handlers of the messages they already
+// received to finish, leaving the connection open. The subscriptions still draining are
+// unsubscribed right away once ctx is done.
+func DrainSubscriptions(ctx context.Context, subs ...*nats.Subscription) error {
+	for _, sub := range subs {
+		if err := sub.Drain(); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for _, sub := range subs {
+		for sub.IsValid() {
+			select {
+			case <-ctx.Done():
+				for _, sub := range subs {
+					sub.Unsubscribe()
+				}
+				return ctx.Err()
+			case <-ticker.C:
+			}
+		}
+	}
+	return nil
+}
+
+// CheckConnection Returns an error unless conn is connected, a reconnecting conn is unavailable.
+func CheckConnection(conn *nats.Conn) error {
+	if status := conn.Status(); status != nats.CONNECTED {
+		return fmt.Errorf("connection is %s", status)
+	}
+	return nil
+}
+
+// Publisher Publishes JSON messages carrying the trace context of the publishing span.
+type Publisher struct {
+	tracer trace.Tracer
+	conn   *nats.Conn
+}
+
+func NewPublisher(tracer trace.Tracer, conn *nats.Conn) *Publisher {
+	return &Publisher{
+		tracer: tracer,
+		conn:   conn,
+	}
+}
+
+func (p *Publisher) Publish(ctx context.Context, subject string, v interface{}) error {
+	_, span := p.tracer.Start(ctx, "Publisher.Publish")
+	defer span.End()
+
+	headers := nats.Header{}
+	headers.Set(tracing.TRACE_ID, span.SpanContext().TraceID().String())
+	headers.Set(tracing.SPAN_ID, span.SpanContext().SpanID().String())
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	msg := nats.Msg{
+		Subject: subject,
+		Header:  headers,
+		Data:    data,
+	}
+
+	err = p.conn.PublishMsg(&msg)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
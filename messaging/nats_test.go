@@ -0,0 +1,90 @@
+package messaging
+
+import (
+	"context"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"testing"
+	"time"
+)
+
+func TestDrainSubscriptions(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	defer s.Shutdown()
+
+	tests := []struct {
+		name string
+		// timeout Of the drain, zero to wait for the handler.
+		timeout time.Duration
+		err     error
+	}{
+		{name: "waits for the handler"},
+		{name: "gives up once ctx is done", timeout: 50 * time.Millisecond, err: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := nats.Connect(s.ClientURL())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			defer close(release)
+			sub, err := conn.Subscribe("drain.test", func(*nats.Msg) {
+				close(started)
+				<-release
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := conn.Publish("drain.test", nil); err != nil {
+				t.Fatal(err)
+			}
+			<-started
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.timeout > 0 {
+				ctx, cancel = context.WithTimeout(context.Background(), tt.timeout)
+			}
+			defer cancel()
+			done := make(chan error, 1)
+			go func() {
+				done <- DrainSubscriptions(ctx, sub)
+			}()
+
+			if tt.timeout == 0 {
+				select {
+				case err := <-done:
+					t.Fatalf("returned %v while the handler was running", err)
+				case <-time.After(50 * time.Millisecond):
+				}
+				release <- struct{}{}
+			}
+
+			select {
+			case err := <-done:
+				if err != tt.err {
+					t.Errorf("got %v, want %v", err, tt.err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("drain didn't return")
+			}
+			if sub.IsValid() {
+				t.Error("subscription still valid")
+			}
+			if !conn.IsConnected() {
+				t.Error("connection closed by draining its subscriptions")
+			}
+		})
+	}
+}
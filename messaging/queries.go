@@ -0,0 +1,274 @@
+package messaging
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"log"
+	"runtime/debug"
+	"social-graph/repository"
+	"social-graph/tracing"
+)
+
+// Subjects of the read-only queries answered over NATS request-reply. Requests and replies
+// are JSON, their schema is in queries.schema.json.
+const (
+	QUERY_IS_FOLLOWING = "socialgraph.query.is_following"
+	QUERY_CAN_ACCESS   = "socialgraph.query.can_access"
+	QUERY_FOLLOWERS    = "socialgraph.query.followers"
+	QUERY_COUNTS       = "socialgraph.query.counts"
+	// QUERY_QUEUE Queue group shared by all replicas, each request is answered once.
+	QUERY_QUEUE = "social-graph-queries"
+	// QUERY_TOKEN Header carrying the token of the service sending a query.
+	QUERY_TOKEN = "Query-Token"
+
+	DefaultFollowersPageSize = 100
+	MaxFollowersPageSize     = 1000
+)
+
+var (
+	errInvalidQuery = errors.New("invalid query")
+	errUnauthorized = errors.New("missing or invalid " + QUERY_TOKEN)
+)
+
+// FollowQuery Request of is_following and can_access, asking about From's relation to To.
+type FollowQuery struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type BoolResult struct {
+	Result bool `json:"result"`
+}
+
+// FollowersQuery Request of a followers page. After is the Next of the previous page.
+type FollowersQuery struct {
+	Username string `json:"username"`
+	After    string `json:"after,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+}
+
+// FollowersPage Next is empty on the last page.
+type FollowersPage struct {
+	Usernames []string `json:"usernames"`
+	Next      string   `json:"next,omitempty"`
+}
+
+type CountsQuery struct {
+	Username string `json:"username"`
+}
+
+type CountsResult struct {
+	Followers int64 `json:"followers"`
+	Following int64 `json:"following"`
+}
+
+// QueryError Reply of a failed query, Code is unauthorized, bad_request, not_found or internal.
+type QueryError struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+type queryHandler func(ctx context.Context, data []byte) (interface{}, error)
+
+type QueryResponder struct {
+	tracer trace.Tracer
+	conn   *nats.Conn
+	repo   repository.SocialGraphRepository
+	token  []byte
+	subs   []*nats.Subscription
+}
+
+// NewQueryResponder Answers only queries carrying token in the QUERY_TOKEN header, since any
+// client of the NATS server can send them.
+func NewQueryResponder(tracer trace.Tracer, conn *nats.Conn, repo repository.SocialGraphRepository, token string) (*QueryResponder, error) {
+	if token == "" {
+		return nil, errors.New("query token is required")
+	}
+	r := &QueryResponder{
+		tracer: tracer,
+		conn:   conn,
+		repo:   repo,
+		token:  []byte(token),
+	}
+
+	handlers := map[string]queryHandler{
+		QUERY_IS_FOLLOWING: r.isFollowing,
+		QUERY_CAN_ACCESS:   r.canAccess,
+		QUERY_FOLLOWERS:    r.followers,
+		QUERY_COUNTS:       r.counts,
+	}
+	for subject, handler := range handlers {
+		sub, err := conn.QueueSubscribe(subject, QUERY_QUEUE, r.respond(handler))
+		if err != nil {
+			return nil, err
+		}
+		r.subs = append(r.subs, sub)
+	}
+
+	return r, nil
+}
+
+// Drain Stops answering queries and waits for the queries being answered.
+func (r *QueryResponder) Drain(ctx context.Context) error {
+	return DrainSubscriptions(ctx, r.subs...)
+}
+
+func (r *QueryResponder) respond(handler queryHandler) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		// queries without trace headers are answered in a new trace
+		remoteCtx, _ := tracing.GetNATSParentContext(msg)
+
+		ctx, span := otel.Tracer("social-graph").Start(trace.ContextWithRemoteSpanContext(context.Background(), remoteCtx), "QueryResponder.respond")
+		defer span.End()
+
+		var reply interface{}
+		var result interface{}
+		err := errUnauthorized
+		if subtle.ConstantTimeCompare([]byte(msg.Header.Get(QUERY_TOKEN)), r.token) == 1 {
+			result, err = safeHandle(handler, ctx, msg.Data)
+		}
+		switch {
+		case err == nil:
+			reply = result
+		case errors.Is(err, errUnauthorized):
+			span.SetStatus(codes.Error, err.Error())
+			reply = QueryError{Error: err.Error(), Code: "unauthorized"}
+		case errors.Is(err, errInvalidQuery):
+			reply = QueryError{Error: err.Error(), Code: "bad_request"}
+		case errors.Is(err, repository.ErrUserNotFound):
+			reply = QueryError{Error: err.Error(), Code: "not_found"}
+		default:
+			span.SetStatus(codes.Error, err.Error())
+			reply = QueryError{Error: err.Error(), Code: "internal"}
+		}
+
+		data, err := json.Marshal(reply)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return
+		}
+
+		headers := nats.Header{}
+		headers.Set(tracing.TRACE_ID, span.SpanContext().TraceID().String())
+		headers.Set(tracing.SPAN_ID, span.SpanContext().SpanID().String())
+
+		err = msg.RespondMsg(&nats.Msg{
+			Subject: msg.Reply,
+			Header:  headers,
+			Data:    data,
+		})
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			log.Printf("failed to respond to %s: %v", msg.Subject, err)
+		}
+	}
+}
+
+func (r *QueryResponder) isFollowing(ctx context.Context, data []byte) (interface{}, error) {
+	queryCtx, span := r.tracer.Start(ctx, "QueryResponder.isFollowing")
+	defer span.End()
+
+	q, err := decodeFollowQuery(data)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := r.repo.CheckIfFollowExists(queryCtx, q.From, q.To)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return BoolResult{Result: exists}, nil
+}
+
+func (r *QueryResponder) canAccess(ctx context.Context, data []byte) (interface{}, error) {
+	queryCtx, span := r.tracer.Start(ctx, "QueryResponder.canAccess")
+	defer span.End()
+
+	q, err := decodeFollowQuery(data)
+	if err != nil {
+		return nil, err
+	}
+
+	visible, err := r.repo.CanAccessTweetOfAnotherUser(queryCtx, q.From, q.To)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return BoolResult{Result: visible}, nil
+}
+
+func (r *QueryResponder) followers(ctx context.Context, data []byte) (interface{}, error) {
+	queryCtx, span := r.tracer.Start(ctx, "QueryResponder.followers")
+	defer span.End()
+
+	var q FollowersQuery
+	if err := json.Unmarshal(data, &q); err != nil || q.Username == "" {
+		return nil, errInvalidQuery
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultFollowersPageSize
+	}
+	if q.Limit < 0 || q.Limit > MaxFollowersPageSize {
+		return nil, errInvalidQuery
+	}
+
+	users, err := r.repo.GetFollowersPage(queryCtx, q.Username, q.After, q.Limit)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	page := FollowersPage{Usernames: []string{}}
+	for _, user := range users {
+		page.Usernames = append(page.Usernames, user.Username)
+	}
+	if len(users) == q.Limit {
+		page.Next = users[len(users)-1].Username
+	}
+	return page, nil
+}
+
+func (r *QueryResponder) counts(ctx context.Context, data []byte) (interface{}, error) {
+	queryCtx, span := r.tracer.Start(ctx, "QueryResponder.counts")
+	defer span.End()
+
+	var q CountsQuery
+	if err := json.Unmarshal(data, &q); err != nil || q.Username == "" {
+		return nil, errInvalidQuery
+	}
+
+	counts, err := r.repo.GetFollowCounts(queryCtx, q.Username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return CountsResult{Followers: counts.Followers, Following: counts.Following}, nil
+}
+
+// safeHandle Turns a panic of handler into an error, so a single bad query can't take down
+// the subscription.
+func safeHandle(handler queryHandler, ctx context.Context, data []byte) (result interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("query panicked: %v\n%s", p, debug.Stack())
+			err = fmt.Errorf("query panicked: %v", p)
+		}
+	}()
+	return handler(ctx, data)
+}
+
+func decodeFollowQuery(data []byte) (FollowQuery, error) {
+	var q FollowQuery
+	if err := json.Unmarshal(data, &q); err != nil || q.From == "" || q.To == "" {
+		return q, errInvalidQuery
+	}
+	return q, nil
+}
@@ -0,0 +1,74 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/trace"
+	"social-graph/repository"
+	"testing"
+	"time"
+)
+
+type queryRepository struct {
+	repository.SocialGraphRepository
+}
+
+func (r queryRepository) CheckIfFollowExists(_ context.Context, from string, to string) (bool, error) {
+	return from == "alice" && to == "bob", nil
+}
+
+func TestQueryResponderRequiresToken(t *testing.T) {
+	s, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	defer s.Shutdown()
+	conn, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tracer := trace.NewNoopTracerProvider().Tracer("test")
+	if _, err := NewQueryResponder(tracer, conn, queryRepository{}, ""); err == nil {
+		t.Error("created a responder without a token")
+	}
+	if _, err := NewQueryResponder(tracer, conn, queryRepository{}, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		reply string
+	}{
+		{name: "valid token", token: "secret", reply: `{"result":true}`},
+		{name: "missing token", reply: `{"error":"missing or invalid Query-Token","code":"unauthorized"}`},
+		{name: "wrong token", token: "secret2", reply: `{"error":"missing or invalid Query-Token","code":"unauthorized"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := json.Marshal(FollowQuery{From: "alice", To: "bob"})
+			msg := nats.NewMsg(QUERY_IS_FOLLOWING)
+			msg.Data = data
+			if tt.token != "" {
+				msg.Header.Set(QUERY_TOKEN, tt.token)
+			}
+
+			reply, err := conn.RequestMsg(msg, 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(reply.Data) != tt.reply {
+				t.Errorf("got %s, want %s", reply.Data, tt.reply)
+			}
+		})
+	}
+}
@@ -14,6 +14,103 @@ type User struct {
 	IsPrivate bool   `json:"private"`
 }
 
+// Follow Edge between two users, either FOLLOWS or a pending FOLLOWS_REQUEST.
+type Follow struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Request bool   `json:"request,omitempty"`
+}
+
 type Approved struct {
 	Approved bool `json:"approved"`
 }
+
+type Privacy struct {
+	Private bool `json:"private"`
+}
+
+// PrivacyChange Going public approves all pending follow requests, going private returns the
+// existing followers so they can be reviewed and removed.
+type PrivacyChange struct {
+	Private   bool   `json:"private"`
+	Approved  []User `json:"approved,omitempty"`
+	Followers []User `json:"followers,omitempty"`
+}
+
+// FollowerGrowth Followers gained and lost during one time bucket starting at Timestamp,
+// and the number of followers at the end of it.
+type FollowerGrowth struct {
+	Timestamp time.Time `json:"timestamp"`
+	Gained    int64     `json:"gained"`
+	Lost      int64     `json:"lost"`
+	Followers int64     `json:"followers"`
+}
+
+type FollowCounts struct {
+	Followers int64 `json:"followers"`
+	Following int64 `json:"following"`
+}
+
+// EgoNetworkUser User of an ego network, Depth FOLLOWS hops away from the ego.
+type EgoNetworkUser struct {
+	User
+	Depth int
+}
+
+// EgoNetwork Subgraph around a user in the Cytoscape elements JSON format.
+type EgoNetwork struct {
+	Elements  GraphElements `json:"elements"`
+	Truncated bool          `json:"truncated"`
+}
+
+type GraphElements struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+type GraphNode struct {
+	Data GraphNodeData `json:"data"`
+}
+
+type GraphNodeData struct {
+	ID      string `json:"id"`
+	Private bool   `json:"private"`
+	Depth   int    `json:"depth"`
+}
+
+type GraphEdge struct {
+	Data GraphEdgeData `json:"data"`
+}
+
+type GraphEdgeData struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+const (
+	// OutboxFeedUpdate Adds the tweets of To to the feed of From.
+	OutboxFeedUpdate = "feed.update"
+	// OutboxFeedRemove Removes the tweets of To from the feed of From.
+	OutboxFeedRemove = "feed.remove"
+)
+
+const (
+	OutboxPending = "pending"
+	// OutboxDead Delivery was given up after too many attempts.
+	OutboxDead = "dead"
+)
+
+// OutboxMessage Side effect saved in the same transaction as the change causing it,
+// delivered afterwards by the outbox dispatcher.
+type OutboxMessage struct {
+	ID            string    `json:"id"`
+	Kind          string    `json:"kind"`
+	From          string    `json:"from"`
+	To            string    `json:"to"`
+	Status        string    `json:"status"`
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"nextAttemptAt"`
+	LastError     string    `json:"lastError,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
@@ -0,0 +1,151 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"log"
+	"math/rand"
+	"social-graph/model"
+	"social-graph/repository"
+	"time"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 50
+	defaultMaxAttempts  = 10
+	defaultBaseBackoff  = time.Second
+	defaultMaxBackoff   = 5 * time.Minute
+	// defaultLease How long a claimed message is hidden from other dispatchers, should exceed
+	// the time needed to deliver a whole batch.
+	defaultLease = time.Minute
+)
+
+// Handler Delivers one outbox message, an error schedules a retry.
+type Handler func(ctx context.Context, msg model.OutboxMessage) error
+
+// Dispatcher Polls the outbox and delivers pending messages to the handler registered for
+// their kind. Failed deliveries are retried with exponential backoff until MaxAttempts,
+// after which the message is left in the dead state. Messages of the same follow are delivered
+// in the order they were created, a later one isn't claimed until the earlier ones are gone.
+type Dispatcher struct {
+	repo     repository.SocialGraphRepository
+	tracer   trace.Tracer
+	handlers map[string]Handler
+
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	Lease        time.Duration
+}
+
+func NewDispatcher(repo repository.SocialGraphRepository, tracer trace.Tracer) *Dispatcher {
+	return &Dispatcher{
+		repo:         repo,
+		tracer:       tracer,
+		handlers:     map[string]Handler{},
+		PollInterval: defaultPollInterval,
+		BatchSize:    defaultBatchSize,
+		MaxAttempts:  defaultMaxAttempts,
+		BaseBackoff:  defaultBaseBackoff,
+		MaxBackoff:   defaultMaxBackoff,
+		Lease:        defaultLease,
+	}
+}
+
+// Handle Registers h for messages of kind. Must be called before Run.
+func (d *Dispatcher) Handle(kind string, h Handler) {
+	d.handlers[kind] = h
+}
+
+// Run Delivers messages until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// keep going without waiting while there are full batches
+		for d.dispatch(ctx) == d.BatchSize {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch Delivers one batch of due messages and returns its size.
+func (d *Dispatcher) dispatch(ctx context.Context) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	messages, err := d.repo.ClaimOutboxMessages(ctx, time.Now(), d.Lease, d.BatchSize)
+	if err != nil {
+		log.Println(err)
+		return 0
+	}
+	for _, msg := range messages {
+		d.deliver(ctx, msg)
+	}
+	return len(messages)
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, msg model.OutboxMessage) {
+	dispatchCtx, span := d.tracer.Start(ctx, "Dispatcher.deliver", trace.WithAttributes(
+		attribute.String("outbox.id", msg.ID),
+		attribute.String("outbox.kind", msg.Kind),
+		attribute.Int("outbox.attempts", msg.Attempts),
+	))
+	defer span.End()
+
+	var err error
+	if h, ok := d.handlers[msg.Kind]; ok {
+		err = h(dispatchCtx, msg)
+	} else {
+		err = errors.New("no handler for outbox message kind " + msg.Kind)
+		// retrying won't help
+		msg.Attempts = d.MaxAttempts
+	}
+
+	if err == nil {
+		if err := d.repo.DeleteOutboxMessage(dispatchCtx, msg.ID); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			log.Println(err)
+		}
+		return
+	}
+
+	span.SetStatus(codes.Error, err.Error())
+	msg.Attempts++
+	msg.LastError = err.Error()
+	msg.NextAttemptAt = time.Now().Add(d.backoff(msg.Attempts))
+	if msg.Attempts >= d.MaxAttempts {
+		msg.Status = model.OutboxDead
+		log.Printf("outbox message %s (%s %s -> %s) is dead after %d attempts: %v", msg.ID, msg.Kind, msg.From, msg.To, msg.Attempts, err)
+	}
+
+	if err := d.repo.UpdateOutboxMessage(dispatchCtx, msg); err != nil {
+		log.Println(err)
+	}
+}
+
+// backoff Returns the delay before the given attempt, doubling from BaseBackoff up to
+// MaxBackoff, with the upper half randomized so that failed messages don't retry in lockstep.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.MaxBackoff
+	if attempts < 32 {
+		if b := d.BaseBackoff << (attempts - 1); b > 0 && b < d.MaxBackoff {
+			delay = b
+		}
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
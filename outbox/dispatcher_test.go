@@ -0,0 +1,172 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel/trace"
+	"social-graph/model"
+	"social-graph/repository"
+	"testing"
+	"time"
+)
+
+type outboxRepository struct {
+	repository.SocialGraphRepository
+	pending []model.OutboxMessage
+	updated []model.OutboxMessage
+	deleted []string
+}
+
+func (r *outboxRepository) ClaimOutboxMessages(_ context.Context, _ time.Time, _ time.Duration, limit int) ([]model.OutboxMessage, error) {
+	if limit > len(r.pending) {
+		limit = len(r.pending)
+	}
+	claimed := r.pending[:limit]
+	r.pending = r.pending[limit:]
+	return claimed, nil
+}
+
+func (r *outboxRepository) UpdateOutboxMessage(_ context.Context, msg model.OutboxMessage) error {
+	r.updated = append(r.updated, msg)
+	return nil
+}
+
+func (r *outboxRepository) DeleteOutboxMessage(_ context.Context, id string) error {
+	r.deleted = append(r.deleted, id)
+	return nil
+}
+
+func TestDispatcherDeliver(t *testing.T) {
+	failing := errors.New("tweet service is down")
+
+	tests := []struct {
+		name     string
+		msg      model.OutboxMessage
+		err      error
+		deleted  bool
+		attempts int
+		status   string
+	}{
+		{
+			name:    "delivered",
+			msg:     model.OutboxMessage{ID: "1", Kind: model.OutboxFeedUpdate},
+			deleted: true,
+		},
+		{
+			name:     "failed",
+			msg:      model.OutboxMessage{ID: "2", Kind: model.OutboxFeedUpdate, Attempts: 3, Status: model.OutboxPending},
+			err:      failing,
+			attempts: 4,
+			status:   model.OutboxPending,
+		},
+		{
+			name:     "last attempt",
+			msg:      model.OutboxMessage{ID: "3", Kind: model.OutboxFeedUpdate, Attempts: defaultMaxAttempts - 1, Status: model.OutboxPending},
+			err:      failing,
+			attempts: defaultMaxAttempts,
+			status:   model.OutboxDead,
+		},
+		{
+			name:     "unknown kind",
+			msg:      model.OutboxMessage{ID: "4", Kind: "feed.unknown", Status: model.OutboxPending},
+			attempts: defaultMaxAttempts + 1,
+			status:   model.OutboxDead,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &outboxRepository{}
+			d := NewDispatcher(repo, trace.NewNoopTracerProvider().Tracer("test"))
+			d.Handle(model.OutboxFeedUpdate, func(context.Context, model.OutboxMessage) error {
+				return tt.err
+			})
+
+			d.deliver(context.Background(), tt.msg)
+
+			if tt.deleted {
+				if len(repo.deleted) != 1 || repo.deleted[0] != tt.msg.ID || len(repo.updated) != 0 {
+					t.Fatalf("deleted %v, updated %+v", repo.deleted, repo.updated)
+				}
+				return
+			}
+			if len(repo.deleted) != 0 || len(repo.updated) != 1 {
+				t.Fatalf("deleted %v, updated %+v", repo.deleted, repo.updated)
+			}
+			msg := repo.updated[0]
+			if msg.Attempts != tt.attempts || msg.Status != tt.status || msg.LastError == "" {
+				t.Errorf("got %+v, want %d attempts in status %s", msg, tt.attempts, tt.status)
+			}
+			if !msg.NextAttemptAt.After(time.Now()) {
+				t.Errorf("next attempt %v is not in the future", msg.NextAttemptAt)
+			}
+		})
+	}
+}
+
+func TestDispatcherBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		max      time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{5, 16 * time.Second},
+		{9, 256 * time.Second},
+		{10, 5 * time.Minute},
+		{40, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+
+	d := NewDispatcher(&outboxRepository{}, trace.NewNoopTracerProvider().Tracer("test"))
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			got := d.backoff(tt.attempts)
+			if got < tt.max/2 || got > tt.max {
+				t.Fatalf("attempt %d waits %v, want between %v and %v", tt.attempts, got, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+func TestDispatcherRunDrainsFullBatches(t *testing.T) {
+	repo := &outboxRepository{}
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		repo.pending = append(repo.pending, model.OutboxMessage{ID: id, Kind: model.OutboxFeedRemove})
+	}
+
+	d := NewDispatcher(repo, trace.NewNoopTracerProvider().Tracer("test"))
+	d.BatchSize = 2
+	// every batch has to be delivered without waiting for a poll
+	d.PollInterval = time.Hour
+	delivered := make(chan string, len(repo.pending))
+	d.Handle(model.OutboxFeedRemove, func(_ context.Context, msg model.OutboxMessage) error {
+		delivered <- msg.ID
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 5; i++ {
+		select {
+		case <-delivered:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("delivered %d of 5 messages", i)
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't return after ctx was done")
+	}
+	if len(repo.deleted) != 5 {
+		t.Errorf("deleted %v, want all 5 messages", repo.deleted)
+	}
+}
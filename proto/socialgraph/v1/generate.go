@@ -0,0 +1,4 @@
+// Package socialgraphv1 Code generated from social_graph.proto, regenerate with go generate.
+package socialgraphv1
+
+//go:generate protoc -I ../../.. --go_out=../../.. --go_opt=module=social-graph --go-grpc_out=../../.. --go-grpc_opt=module=social-graph proto/socialgraph/v1/social_graph.proto
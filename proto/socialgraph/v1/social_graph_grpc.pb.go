@@ -0,0 +1,729 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.21.9
+// source: proto/socialgraph/v1/social_graph.proto
+
+package socialgraphv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// SocialGraphServiceClient is the client API for SocialGraphService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SocialGraphServiceClient interface {
+	// CreateFollow Follows a public user, or asks a private user to be followed.
+	CreateFollow(ctx context.Context, in *CreateFollowRequest, opts ...grpc.CallOption) (*CreateFollowResponse, error)
+	RemoveFollow(ctx context.Context, in *RemoveFollowRequest, opts ...grpc.CallOption) (*RemoveFollowResponse, error)
+	// RemoveFollower Makes username stop following the caller.
+	RemoveFollower(ctx context.Context, in *RemoveFollowerRequest, opts ...grpc.CallOption) (*RemoveFollowerResponse, error)
+	UpdatePrivacy(ctx context.Context, in *UpdatePrivacyRequest, opts ...grpc.CallOption) (*UpdatePrivacyResponse, error)
+	GetFollowing(ctx context.Context, in *GetFollowingRequest, opts ...grpc.CallOption) (*GetFollowingResponse, error)
+	GetFollowingCount(ctx context.Context, in *GetFollowingCountRequest, opts ...grpc.CallOption) (*GetFollowingCountResponse, error)
+	GetFollowers(ctx context.Context, in *GetFollowersRequest, opts ...grpc.CallOption) (*GetFollowersResponse, error)
+	// StreamFollowers Streams followers in chunks ordered by username, for fan-out to accounts
+	// with too many followers for GetFollowers. A broken stream is resumed by sending the cursor
+	// of the last received chunk.
+	StreamFollowers(ctx context.Context, in *StreamFollowersRequest, opts ...grpc.CallOption) (SocialGraphService_StreamFollowersClient, error)
+	GetFollowersCount(ctx context.Context, in *GetFollowersCountRequest, opts ...grpc.CallOption) (*GetFollowersCountResponse, error)
+	// CheckFollowExists Whether the caller follows username.
+	CheckFollowExists(ctx context.Context, in *CheckFollowExistsRequest, opts ...grpc.CallOption) (*CheckFollowExistsResponse, error)
+	// AcceptRejectFollowRequest Answers the follow request of username to the caller.
+	AcceptRejectFollowRequest(ctx context.Context, in *AcceptRejectFollowRequestRequest, opts ...grpc.CallOption) (*AcceptRejectFollowRequestResponse, error)
+	// CheckFollowRequestExists Whether username asked to follow the caller.
+	CheckFollowRequestExists(ctx context.Context, in *CheckFollowRequestExistsRequest, opts ...grpc.CallOption) (*CheckFollowRequestExistsResponse, error)
+	ListFollowRequests(ctx context.Context, in *ListFollowRequestsRequest, opts ...grpc.CallOption) (*ListFollowRequestsResponse, error)
+	// CheckVisibility Whether viewer can see the tweets of each of usernames, at most 1000 of them.
+	// Users that don't exist are not visible.
+	CheckVisibility(ctx context.Context, in *CheckVisibilityRequest, opts ...grpc.CallOption) (*CheckVisibilityResponse, error)
+	GetRecommendations(ctx context.Context, in *GetRecommendationsRequest, opts ...grpc.CallOption) (*GetRecommendationsResponse, error)
+	GetFollowerGrowth(ctx context.Context, in *GetFollowerGrowthRequest, opts ...grpc.CallOption) (*GetFollowerGrowthResponse, error)
+	GetEgoNetwork(ctx context.Context, in *GetEgoNetworkRequest, opts ...grpc.CallOption) (*GetEgoNetworkResponse, error)
+}
+
+type socialGraphServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSocialGraphServiceClient(cc grpc.ClientConnInterface) SocialGraphServiceClient {
+	return &socialGraphServiceClient{cc}
+}
+
+func (c *socialGraphServiceClient) CreateFollow(ctx context.Context, in *CreateFollowRequest, opts ...grpc.CallOption) (*CreateFollowResponse, error) {
+	out := new(CreateFollowResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/CreateFollow", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) RemoveFollow(ctx context.Context, in *RemoveFollowRequest, opts ...grpc.CallOption) (*RemoveFollowResponse, error) {
+	out := new(RemoveFollowResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/RemoveFollow", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) RemoveFollower(ctx context.Context, in *RemoveFollowerRequest, opts ...grpc.CallOption) (*RemoveFollowerResponse, error) {
+	out := new(RemoveFollowerResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/RemoveFollower", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) UpdatePrivacy(ctx context.Context, in *UpdatePrivacyRequest, opts ...grpc.CallOption) (*UpdatePrivacyResponse, error) {
+	out := new(UpdatePrivacyResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/UpdatePrivacy", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) GetFollowing(ctx context.Context, in *GetFollowingRequest, opts ...grpc.CallOption) (*GetFollowingResponse, error) {
+	out := new(GetFollowingResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/GetFollowing", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) GetFollowingCount(ctx context.Context, in *GetFollowingCountRequest, opts ...grpc.CallOption) (*GetFollowingCountResponse, error) {
+	out := new(GetFollowingCountResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/GetFollowingCount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) GetFollowers(ctx context.Context, in *GetFollowersRequest, opts ...grpc.CallOption) (*GetFollowersResponse, error) {
+	out := new(GetFollowersResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/GetFollowers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) StreamFollowers(ctx context.Context, in *StreamFollowersRequest, opts ...grpc.CallOption) (SocialGraphService_StreamFollowersClient, error) {
+	stream, err := c.cc.NewStream(ctx, &SocialGraphService_ServiceDesc.Streams[0], "/socialgraph.v1.SocialGraphService/StreamFollowers", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &socialGraphServiceStreamFollowersClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type SocialGraphService_StreamFollowersClient interface {
+	Recv() (*StreamFollowersResponse, error)
+	grpc.ClientStream
+}
+
+type socialGraphServiceStreamFollowersClient struct {
+	grpc.ClientStream
+}
+
+func (x *socialGraphServiceStreamFollowersClient) Recv() (*StreamFollowersResponse, error) {
+	m := new(StreamFollowersResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *socialGraphServiceClient) GetFollowersCount(ctx context.Context, in *GetFollowersCountRequest, opts ...grpc.CallOption) (*GetFollowersCountResponse, error) {
+	out := new(GetFollowersCountResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/GetFollowersCount", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) CheckFollowExists(ctx context.Context, in *CheckFollowExistsRequest, opts ...grpc.CallOption) (*CheckFollowExistsResponse, error) {
+	out := new(CheckFollowExistsResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/CheckFollowExists", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) AcceptRejectFollowRequest(ctx context.Context, in *AcceptRejectFollowRequestRequest, opts ...grpc.CallOption) (*AcceptRejectFollowRequestResponse, error) {
+	out := new(AcceptRejectFollowRequestResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/AcceptRejectFollowRequest", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) CheckFollowRequestExists(ctx context.Context, in *CheckFollowRequestExistsRequest, opts ...grpc.CallOption) (*CheckFollowRequestExistsResponse, error) {
+	out := new(CheckFollowRequestExistsResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/CheckFollowRequestExists", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) ListFollowRequests(ctx context.Context, in *ListFollowRequestsRequest, opts ...grpc.CallOption) (*ListFollowRequestsResponse, error) {
+	out := new(ListFollowRequestsResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/ListFollowRequests", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) CheckVisibility(ctx context.Context, in *CheckVisibilityRequest, opts ...grpc.CallOption) (*CheckVisibilityResponse, error) {
+	out := new(CheckVisibilityResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/CheckVisibility", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) GetRecommendations(ctx context.Context, in *GetRecommendationsRequest, opts ...grpc.CallOption) (*GetRecommendationsResponse, error) {
+	out := new(GetRecommendationsResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/GetRecommendations", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) GetFollowerGrowth(ctx context.Context, in *GetFollowerGrowthRequest, opts ...grpc.CallOption) (*GetFollowerGrowthResponse, error) {
+	out := new(GetFollowerGrowthResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/GetFollowerGrowth", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *socialGraphServiceClient) GetEgoNetwork(ctx context.Context, in *GetEgoNetworkRequest, opts ...grpc.CallOption) (*GetEgoNetworkResponse, error) {
+	out := new(GetEgoNetworkResponse)
+	err := c.cc.Invoke(ctx, "/socialgraph.v1.SocialGraphService/GetEgoNetwork", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SocialGraphServiceServer is the server API for SocialGraphService service.
+// All implementations must embed UnimplementedSocialGraphServiceServer
+// for forward compatibility
+type SocialGraphServiceServer interface {
+	// CreateFollow Follows a public user, or asks a private user to be followed.
+	CreateFollow(context.Context, *CreateFollowRequest) (*CreateFollowResponse, error)
+	RemoveFollow(context.Context, *RemoveFollowRequest) (*RemoveFollowResponse, error)
+	// RemoveFollower Makes username stop following the caller.
+	RemoveFollower(context.Context, *RemoveFollowerRequest) (*RemoveFollowerResponse, error)
+	UpdatePrivacy(context.Context, *UpdatePrivacyRequest) (*UpdatePrivacyResponse, error)
+	GetFollowing(context.Context, *GetFollowingRequest) (*GetFollowingResponse, error)
+	GetFollowingCount(context.Context, *GetFollowingCountRequest) (*GetFollowingCountResponse, error)
+	GetFollowers(context.Context, *GetFollowersRequest) (*GetFollowersResponse, error)
+	// StreamFollowers Streams followers in chunks ordered by username, for fan-out to accounts
+	// with too many followers for GetFollowers. A broken stream is resumed by sending the cursor
+	// of the last received chunk.
+	StreamFollowers(*StreamFollowersRequest, SocialGraphService_StreamFollowersServer) error
+	GetFollowersCount(context.Context, *GetFollowersCountRequest) (*GetFollowersCountResponse, error)
+	// CheckFollowExists Whether the caller follows username.
+	CheckFollowExists(context.Context, *CheckFollowExistsRequest) (*CheckFollowExistsResponse, error)
+	// AcceptRejectFollowRequest Answers the follow request of username to the caller.
+	AcceptRejectFollowRequest(context.Context, *AcceptRejectFollowRequestRequest) (*AcceptRejectFollowRequestResponse, error)
+	// CheckFollowRequestExists Whether username asked to follow the caller.
+	CheckFollowRequestExists(context.Context, *CheckFollowRequestExistsRequest) (*CheckFollowRequestExistsResponse, error)
+	ListFollowRequests(context.Context, *ListFollowRequestsRequest) (*ListFollowRequestsResponse, error)
+	// CheckVisibility Whether viewer can see the tweets of each of usernames, at most 1000 of them.
+	// Users that don't exist are not visible.
+	CheckVisibility(context.Context, *CheckVisibilityRequest) (*CheckVisibilityResponse, error)
+	GetRecommendations(context.Context, *GetRecommendationsRequest) (*GetRecommendationsResponse, error)
+	GetFollowerGrowth(context.Context, *GetFollowerGrowthRequest) (*GetFollowerGrowthResponse, error)
+	GetEgoNetwork(context.Context, *GetEgoNetworkRequest) (*GetEgoNetworkResponse, error)
+	mustEmbedUnimplementedSocialGraphServiceServer()
+}
+
+// UnimplementedSocialGraphServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedSocialGraphServiceServer struct {
+}
+
+func (UnimplementedSocialGraphServiceServer) CreateFollow(context.Context, *CreateFollowRequest) (*CreateFollowResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateFollow not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) RemoveFollow(context.Context, *RemoveFollowRequest) (*RemoveFollowResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveFollow not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) RemoveFollower(context.Context, *RemoveFollowerRequest) (*RemoveFollowerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveFollower not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) UpdatePrivacy(context.Context, *UpdatePrivacyRequest) (*UpdatePrivacyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdatePrivacy not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) GetFollowing(context.Context, *GetFollowingRequest) (*GetFollowingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFollowing not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) GetFollowingCount(context.Context, *GetFollowingCountRequest) (*GetFollowingCountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFollowingCount not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) GetFollowers(context.Context, *GetFollowersRequest) (*GetFollowersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFollowers not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) StreamFollowers(*StreamFollowersRequest, SocialGraphService_StreamFollowersServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamFollowers not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) GetFollowersCount(context.Context, *GetFollowersCountRequest) (*GetFollowersCountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFollowersCount not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) CheckFollowExists(context.Context, *CheckFollowExistsRequest) (*CheckFollowExistsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckFollowExists not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) AcceptRejectFollowRequest(context.Context, *AcceptRejectFollowRequestRequest) (*AcceptRejectFollowRequestResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcceptRejectFollowRequest not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) CheckFollowRequestExists(context.Context, *CheckFollowRequestExistsRequest) (*CheckFollowRequestExistsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckFollowRequestExists not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) ListFollowRequests(context.Context, *ListFollowRequestsRequest) (*ListFollowRequestsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFollowRequests not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) CheckVisibility(context.Context, *CheckVisibilityRequest) (*CheckVisibilityResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckVisibility not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) GetRecommendations(context.Context, *GetRecommendationsRequest) (*GetRecommendationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRecommendations not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) GetFollowerGrowth(context.Context, *GetFollowerGrowthRequest) (*GetFollowerGrowthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFollowerGrowth not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) GetEgoNetwork(context.Context, *GetEgoNetworkRequest) (*GetEgoNetworkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEgoNetwork not implemented")
+}
+func (UnimplementedSocialGraphServiceServer) mustEmbedUnimplementedSocialGraphServiceServer() {}
+
+// UnsafeSocialGraphServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SocialGraphServiceServer will
+// result in compilation errors.
+type UnsafeSocialGraphServiceServer interface {
+	mustEmbedUnimplementedSocialGraphServiceServer()
+}
+
+func RegisterSocialGraphServiceServer(s grpc.ServiceRegistrar, srv SocialGraphServiceServer) {
+	s.RegisterService(&SocialGraphService_ServiceDesc, srv)
+}
+
+func _SocialGraphService_CreateFollow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateFollowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).CreateFollow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/CreateFollow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).CreateFollow(ctx, req.(*CreateFollowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_RemoveFollow_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveFollowRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).RemoveFollow(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/RemoveFollow",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).RemoveFollow(ctx, req.(*RemoveFollowRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_RemoveFollower_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveFollowerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).RemoveFollower(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/RemoveFollower",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).RemoveFollower(ctx, req.(*RemoveFollowerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_UpdatePrivacy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdatePrivacyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).UpdatePrivacy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/UpdatePrivacy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).UpdatePrivacy(ctx, req.(*UpdatePrivacyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_GetFollowing_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFollowingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).GetFollowing(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/GetFollowing",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).GetFollowing(ctx, req.(*GetFollowingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_GetFollowingCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFollowingCountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).GetFollowingCount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/GetFollowingCount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).GetFollowingCount(ctx, req.(*GetFollowingCountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_GetFollowers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFollowersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).GetFollowers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/GetFollowers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).GetFollowers(ctx, req.(*GetFollowersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_StreamFollowers_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamFollowersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SocialGraphServiceServer).StreamFollowers(m, &socialGraphServiceStreamFollowersServer{stream})
+}
+
+type SocialGraphService_StreamFollowersServer interface {
+	Send(*StreamFollowersResponse) error
+	grpc.ServerStream
+}
+
+type socialGraphServiceStreamFollowersServer struct {
+	grpc.ServerStream
+}
+
+func (x *socialGraphServiceStreamFollowersServer) Send(m *StreamFollowersResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _SocialGraphService_GetFollowersCount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFollowersCountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).GetFollowersCount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/GetFollowersCount",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).GetFollowersCount(ctx, req.(*GetFollowersCountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_CheckFollowExists_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckFollowExistsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).CheckFollowExists(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/CheckFollowExists",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).CheckFollowExists(ctx, req.(*CheckFollowExistsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_AcceptRejectFollowRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AcceptRejectFollowRequestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).AcceptRejectFollowRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/AcceptRejectFollowRequest",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).AcceptRejectFollowRequest(ctx, req.(*AcceptRejectFollowRequestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_CheckFollowRequestExists_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckFollowRequestExistsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).CheckFollowRequestExists(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/CheckFollowRequestExists",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).CheckFollowRequestExists(ctx, req.(*CheckFollowRequestExistsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_ListFollowRequests_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFollowRequestsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).ListFollowRequests(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/ListFollowRequests",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).ListFollowRequests(ctx, req.(*ListFollowRequestsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_CheckVisibility_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckVisibilityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).CheckVisibility(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/CheckVisibility",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).CheckVisibility(ctx, req.(*CheckVisibilityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_GetRecommendations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRecommendationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).GetRecommendations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/GetRecommendations",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).GetRecommendations(ctx, req.(*GetRecommendationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_GetFollowerGrowth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFollowerGrowthRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).GetFollowerGrowth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/GetFollowerGrowth",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).GetFollowerGrowth(ctx, req.(*GetFollowerGrowthRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SocialGraphService_GetEgoNetwork_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEgoNetworkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SocialGraphServiceServer).GetEgoNetwork(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/socialgraph.v1.SocialGraphService/GetEgoNetwork",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SocialGraphServiceServer).GetEgoNetwork(ctx, req.(*GetEgoNetworkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// SocialGraphService_ServiceDesc is the grpc.ServiceDesc for SocialGraphService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SocialGraphService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "socialgraph.v1.SocialGraphService",
+	HandlerType: (*SocialGraphServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateFollow",
+			Handler:    _SocialGraphService_CreateFollow_Handler,
+		},
+		{
+			MethodName: "RemoveFollow",
+			Handler:    _SocialGraphService_RemoveFollow_Handler,
+		},
+		{
+			MethodName: "RemoveFollower",
+			Handler:    _SocialGraphService_RemoveFollower_Handler,
+		},
+		{
+			MethodName: "UpdatePrivacy",
+			Handler:    _SocialGraphService_UpdatePrivacy_Handler,
+		},
+		{
+			MethodName: "GetFollowing",
+			Handler:    _SocialGraphService_GetFollowing_Handler,
+		},
+		{
+			MethodName: "GetFollowingCount",
+			Handler:    _SocialGraphService_GetFollowingCount_Handler,
+		},
+		{
+			MethodName: "GetFollowers",
+			Handler:    _SocialGraphService_GetFollowers_Handler,
+		},
+		{
+			MethodName: "GetFollowersCount",
+			Handler:    _SocialGraphService_GetFollowersCount_Handler,
+		},
+		{
+			MethodName: "CheckFollowExists",
+			Handler:    _SocialGraphService_CheckFollowExists_Handler,
+		},
+		{
+			MethodName: "AcceptRejectFollowRequest",
+			Handler:    _SocialGraphService_AcceptRejectFollowRequest_Handler,
+		},
+		{
+			MethodName: "CheckFollowRequestExists",
+			Handler:    _SocialGraphService_CheckFollowRequestExists_Handler,
+		},
+		{
+			MethodName: "ListFollowRequests",
+			Handler:    _SocialGraphService_ListFollowRequests_Handler,
+		},
+		{
+			MethodName: "CheckVisibility",
+			Handler:    _SocialGraphService_CheckVisibility_Handler,
+		},
+		{
+			MethodName: "GetRecommendations",
+			Handler:    _SocialGraphService_GetRecommendations_Handler,
+		},
+		{
+			MethodName: "GetFollowerGrowth",
+			Handler:    _SocialGraphService_GetFollowerGrowth_Handler,
+		},
+		{
+			MethodName: "GetEgoNetwork",
+			Handler:    _SocialGraphService_GetEgoNetwork_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamFollowers",
+			Handler:       _SocialGraphService_StreamFollowers_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/socialgraph/v1/social_graph.proto",
+}
@@ -7,8 +7,10 @@ import (
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 	"log"
-	"os"
+	"social-graph/config"
 	"social-graph/model"
+	"social-graph/repository"
+	"time"
 )
 
 type RepositoryNeo4j struct {
@@ -20,17 +22,35 @@ const (
 	query       = "MATCH (u:User)%s(following)\nWHERE u.username = $username RETURN following.username as username, following.private as private"
 	followQuery = "Match(f:User {username:$from })\nMatch(t:User {username:$to}) \nMerge(f)-[:%s]->(t)"
 	removeQuery = "MATCH (f {username: $from})-[r:%s]->(t {username: $to})DELETE r"
+
+	followRequestQuery = "MATCH (f:User {username: $from})\nMATCH (t:User {username: $to})\nWHERE NOT (f)-[:FOLLOWS]->(t)\nMERGE (f)-[:FOLLOWS_REQUEST]->(t)"
+
+	approvedFollowQuery           = "MATCH (f:User {username: $from})\nMATCH (t:User {username: $to})\nWHERE NOT (f)-[:FOLLOWS]->(t)\nCREATE (f)-[:FOLLOWS]->(t)\nCREATE (:FollowEvent {username: $to, follower: $from, gained: true, timestamp: $timestamp})\nCREATE (:Outbox {id: randomUUID(), kind: 'feed.update', from: $from, to: $to, status: 'pending', attempts: 0, nextAttemptAt: $timestamp, createdAt: $timestamp})"
+	acceptFollowRequestQuery      = "MATCH (f:User {username: $from})-[r:FOLLOWS_REQUEST]->(t:User {username: $to})\nDELETE r\nWITH f, t\nWHERE NOT (f)-[:FOLLOWS]->(t)\nCREATE (f)-[:FOLLOWS]->(t)\nCREATE (:FollowEvent {username: $to, follower: $from, gained: true, timestamp: $timestamp})\nCREATE (:Outbox {id: randomUUID(), kind: 'feed.update', from: $from, to: $to, status: 'pending', attempts: 0, nextAttemptAt: $timestamp, createdAt: $timestamp})"
+	approveAllFollowRequestsQuery = "MATCH (f:User)-[r:FOLLOWS_REQUEST]->(t:User {username: $username})\nDELETE r\nWITH f, t\nWHERE NOT (f)-[:FOLLOWS]->(t)\nCREATE (f)-[:FOLLOWS]->(t)\nCREATE (:FollowEvent {username: $username, follower: f.username, gained: true, timestamp: $timestamp})\nCREATE (:Outbox {id: randomUUID(), kind: 'feed.update', from: f.username, to: $username, status: 'pending', attempts: 0, nextAttemptAt: $timestamp, createdAt: $timestamp})\nRETURN f.username as username, f.private as private"
+	batchFollowQuery              = "UNWIND $follows as row\nMATCH (f:User {username: row.from})\nMATCH (t:User {username: row.to})\nWHERE NOT (f)-[:FOLLOWS]->(t)\nCREATE (f)-[:FOLLOWS]->(t)\nCREATE (:FollowEvent {username: row.to, follower: row.from, gained: true, timestamp: $timestamp})\nCREATE (:Outbox {id: randomUUID(), kind: 'feed.update', from: row.from, to: row.to, status: 'pending', attempts: 0, nextAttemptAt: $timestamp, createdAt: $timestamp})\nRETURN row.i as i"
+	batchFollowRequestQuery       = "UNWIND $follows as row\nMATCH (f:User {username: row.from})\nMATCH (t:User {username: row.to})\nWHERE NOT (f)-[:FOLLOWS]->(t) AND NOT (f)-[:FOLLOWS_REQUEST]->(t)\nCREATE (f)-[:FOLLOWS_REQUEST]->(t)\nRETURN row.i as i"
+	checkVisibilityQuery          = "OPTIONAL MATCH (v:User {username: $viewer})\nUNWIND $usernames as username\nOPTIONAL MATCH (u:User {username: username})\nRETURN username, u IS NOT NULL AND (username = $viewer OR NOT u.private OR (v IS NOT NULL AND exists((v)-[:FOLLOWS]->(u)))) as visible"
+	egoQuery                      = "OPTIONAL MATCH (v:User {username: $viewer})\nMATCH (u:User {username: $username})\nRETURN u.private as private, u.username = $viewer OR NOT u.private OR (v IS NOT NULL AND exists((v)-[:FOLLOWS]->(u))) as visible"
+	egoNeighboursQuery            = "OPTIONAL MATCH (v:User {username: $viewer})\nMATCH (f:User)-[:FOLLOWS]-(u:User)\nWHERE f.username IN $frontier AND NOT u.username IN $seen AND (u.username = $viewer OR NOT u.private OR (v IS NOT NULL AND exists((v)-[:FOLLOWS]->(u))))\nWITH DISTINCT u\nRETURN u.username as username, u.private as private ORDER BY username LIMIT $limit"
+	deleteUserQuery               = "MATCH (u:User {username: $username})%s\nOPTIONAL MATCH (f:User)-[:FOLLOWS]->(u)\nWITH u, collect(f.username) as followers\nOPTIONAL MATCH (u)-[:FOLLOWS]->(t:User)\nWITH u, followers, collect(t.username) as following\nFOREACH (follower IN followers | CREATE (:Outbox {id: randomUUID(), kind: 'feed.remove', from: follower, to: $username, status: 'pending', attempts: 0, nextAttemptAt: $timestamp, createdAt: $timestamp}))\nFOREACH (followed IN following | CREATE (:FollowEvent {username: followed, follower: $username, gained: false, timestamp: $timestamp}))\nDETACH DELETE u"
+	removeApprovedFollowQuery     = "MATCH (f:User {username: $from})-[r:FOLLOWS]->(t:User {username: $to})\nDELETE r\nCREATE (:FollowEvent {username: $to, follower: $from, gained: false, timestamp: $timestamp})\nCREATE (:Outbox {id: randomUUID(), kind: 'feed.remove', from: $from, to: $to, status: 'pending', attempts: 0, nextAttemptAt: $timestamp, createdAt: $timestamp})"
 )
 
-func NewRepositoryNeo4j(tracer trace.Tracer) (*RepositoryNeo4j, error) {
-	db := os.Getenv("DB")
-	dbport := os.Getenv("DBPORT")
-	user := os.Getenv("DB_USER")
-	pass := os.Getenv("DB_PASS")
+// indexes Created at startup if missing. Follow events are looked up by user and time range,
+// the outbox dispatcher polls for due pending messages, looks for older ones of the same follow
+// and updates them by id.
+var indexes = []string{
+	"CREATE INDEX follow_event_username_timestamp IF NOT EXISTS FOR (e:FollowEvent) ON (e.username, e.timestamp)",
+	"CREATE INDEX outbox_status_next_attempt_at IF NOT EXISTS FOR (o:Outbox) ON (o.status, o.nextAttemptAt)",
+	"CREATE INDEX outbox_id IF NOT EXISTS FOR (o:Outbox) ON (o.id)",
+	"CREATE INDEX outbox_from_to IF NOT EXISTS FOR (o:Outbox) ON (o.from, o.to)",
+}
 
-	url := fmt.Sprintf("neo4j://%s:%s", db, dbport)
+func NewRepositoryNeo4j(tracer trace.Tracer, c config.Neo4j) (*RepositoryNeo4j, error) {
+	url := fmt.Sprintf("neo4j://%s:%s", c.Host, c.Port)
 
-	driver, err := neo4j.NewDriver(url, neo4j.BasicAuth(user, pass, ""))
+	driver, err := neo4j.NewDriver(url, neo4j.BasicAuth(c.User, c.Password, ""))
 	if err != nil {
 		return nil, err
 	}
@@ -41,6 +61,62 @@ func NewRepositoryNeo4j(tracer trace.Tracer) (*RepositoryNeo4j, error) {
 	}, err
 }
 
+// CreateIndexes Creates the indexes the queries of the repository rely on, existing ones are kept.
+func (repo *RepositoryNeo4j) CreateIndexes(ctx context.Context) error {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.CreateIndexes")
+	defer span.End()
+
+	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+
+	// schema changes can't share a transaction with each other
+	for _, index := range indexes {
+		_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+			return tx.Run(index, nil)
+		})
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// Close Closes the connections of the driver, once no session is in use.
+func (repo *RepositoryNeo4j) Close() error {
+	return repo.driver.Close()
+}
+
+// Ping Checks that the database answers a query. The driver doesn't take a context, so Ping
+// returns once ctx is done and leaves the query to finish on its own.
+func (repo *RepositoryNeo4j) Ping(ctx context.Context) error {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.Ping")
+	defer span.End()
+
+	done := make(chan error, 1)
+	go func() {
+		session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+		defer session.Close()
+		result, err := session.Run("RETURN 1", nil)
+		if err == nil {
+			_, err = result.Consume()
+		}
+		done <- err
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
 func (repo *RepositoryNeo4j) GetUser(ctx context.Context, username string) (model.User, error) {
 	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.GetUser")
 	defer span.End()
@@ -54,11 +130,10 @@ func (repo *RepositoryNeo4j) GetUser(ctx context.Context, username string) (mode
 			log.Println(err)
 			return nil, err
 		}
-		result.Next()
-		r := result.Record()
-		if r == nil {
-			return false, nil
+		if !result.Next() {
+			return nil, repository.ErrUserNotFound
 		}
+		r := result.Record()
 		u, _ := r.Get("username")
 		p, _ := r.Get("private")
 		return model.User{Username: u.(string), IsPrivate: p.(bool)}, nil
@@ -80,7 +155,7 @@ func (repo *RepositoryNeo4j) CreateNewUser(ctx context.Context, username string,
 	defer session.Close()
 	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
 
-		_, err := tx.Run("Merge(u:User {username: $username, private: $private})", map[string]interface{}{"username": username, "private": isPrivate})
+		_, err := tx.Run("MERGE (u:User {username: $username}) SET u.private = $private", map[string]interface{}{"username": username, "private": isPrivate})
 		if err != nil {
 			span.SetStatus(codes.Error, err.Error())
 			log.Println(err)
@@ -97,68 +172,194 @@ func (repo *RepositoryNeo4j) CreateNewUser(ctx context.Context, username string,
 
 }
 
-func (repo *RepositoryNeo4j) SaveApprovedFollow(ctx context.Context, fromUsername string, toUsername string) error {
+func (repo *RepositoryNeo4j) RegisterUser(ctx context.Context, username string, isPrivate bool) error {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.RegisterUser")
+	defer span.End()
+
+	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+
+	defer session.Close()
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		// an existing user is left as it is, so that rolling back the registration keeps it
+		_, err := tx.Run("MERGE (u:User {username: $username})\nON CREATE SET u.private = $private, u.registering = $timestamp", map[string]interface{}{"username": username, "private": isPrivate, "timestamp": time.Now().UnixMilli()})
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			log.Println(err)
+			return nil, err
+		}
+		return nil, nil
+	})
+
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (repo *RepositoryNeo4j) ConfirmRegistration(ctx context.Context, username string) error {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.ConfirmRegistration")
+	defer span.End()
+
+	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+
+	defer session.Close()
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		_, err := tx.Run("MATCH (u:User {username: $username})\nREMOVE u.registering", map[string]interface{}{"username": username})
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			log.Println(err)
+			return nil, err
+		}
+		return nil, nil
+	})
+
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (repo *RepositoryNeo4j) RollbackRegistration(ctx context.Context, username string, since time.Time) (bool, error) {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.RollbackRegistration")
+	defer span.End()
+
+	deleted, err := repo.deleteUser(username, fmt.Sprintf(deleteUserQuery, "\nWHERE u.registering >= $since"), map[string]interface{}{"since": since.UnixMilli()})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return false, err
+	}
+	return deleted, nil
+}
+
+func (repo *RepositoryNeo4j) DeleteUser(ctx context.Context, username string) error {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.DeleteUser")
+	defer span.End()
+
+	_, err := repo.deleteUser(username, fmt.Sprintf(deleteUserQuery, ""), nil)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+// deleteUser Runs query deleting the user, with params next to the username and timestamp,
+// and, if it matched, the follow events of the user.
+func (repo *RepositoryNeo4j) deleteUser(username string, query string, params map[string]interface{}) (bool, error) {
+	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+	rez, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		queryParams := map[string]interface{}{"username": username, "timestamp": time.Now().UnixMilli()}
+		for k, v := range params {
+			queryParams[k] = v
+		}
+		result, err := tx.Run(query, queryParams)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		summary, err := result.Consume()
+		if err != nil {
+			return nil, err
+		}
+		if summary.Counters().NodesDeleted() == 0 {
+			return false, nil
+		}
+		_, err = tx.Run("MATCH (e:FollowEvent {username: $username}) DELETE e", map[string]interface{}{"username": username})
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		return true, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return rez.(bool), nil
+}
+
+func (repo *RepositoryNeo4j) SaveApprovedFollow(ctx context.Context, fromUsername string, toUsername string) (bool, error) {
 	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.SaveApprovedFollow")
 	defer span.End()
-	return repo.SaveFollow(ctx, fromUsername, toUsername, fmt.Sprintf(followQuery, "FOLLOWS"))
+	return repo.SaveFollow(ctx, fromUsername, toUsername, approvedFollowQuery)
 }
-func (repo *RepositoryNeo4j) SaveFollowRequest(ctx context.Context, fromUsername string, toUsername string) error {
+func (repo *RepositoryNeo4j) SaveFollowRequest(ctx context.Context, fromUsername string, toUsername string) (bool, error) {
 	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.SaveFollowRequest")
 	defer span.End()
-	return repo.SaveFollow(ctx, fromUsername, toUsername, fmt.Sprintf(followQuery, "FOLLOWS_REQUEST"))
+	return repo.SaveFollow(ctx, fromUsername, toUsername, followRequestQuery)
 }
-func (repo *RepositoryNeo4j) SaveFollow(ctx context.Context, fromUsername string, toUsername string, query string) error {
+
+// SaveFollow Runs query and returns whether it created a relationship.
+func (repo *RepositoryNeo4j) SaveFollow(ctx context.Context, fromUsername string, toUsername string, query string) (bool, error) {
 	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.SaveFollow")
 	defer span.End()
 
 	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
 
 	defer session.Close()
-	_, er := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
-		_, err := tx.Run(query, map[string]interface{}{"from": fromUsername, "to": toUsername})
+	rez, er := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(query, map[string]interface{}{"from": fromUsername, "to": toUsername, "timestamp": time.Now().UnixMilli()})
 		if err != nil {
 			span.SetStatus(codes.Error, err.Error())
 			log.Println(err)
 			return nil, err
 		}
-		return nil, nil
+		summary, err := result.Consume()
+		if err != nil {
+			return nil, err
+		}
+		return summary.Counters().RelationshipsCreated() > 0, nil
 	})
 	if er != nil {
 		span.SetStatus(codes.Error, er.Error())
-		return er
+		return false, er
 	}
-	return nil
+	return rez.(bool), nil
 }
-func (repo *RepositoryNeo4j) RemoveApprovedFollow(ctx context.Context, fromUsername string, toUsername string) error {
+func (repo *RepositoryNeo4j) RestoreFollow(ctx context.Context, fromUsername string, toUsername string) error {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.RestoreFollow")
+	defer span.End()
+	_, err := repo.SaveFollow(ctx, fromUsername, toUsername, fmt.Sprintf(followQuery, "FOLLOWS"))
+	return err
+}
+func (repo *RepositoryNeo4j) RemoveApprovedFollow(ctx context.Context, fromUsername string, toUsername string) (bool, error) {
 	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.RemoveApprovedFollow")
 	defer span.End()
-	return repo.RemoveFollow(ctx, fromUsername, toUsername, fmt.Sprintf(removeQuery, "FOLLOWS"))
+	return repo.RemoveFollow(ctx, fromUsername, toUsername, removeApprovedFollowQuery)
 }
-func (repo *RepositoryNeo4j) RemoveFollowRequest(ctx context.Context, fromUsername string, toUsername string) error {
+func (repo *RepositoryNeo4j) RemoveFollowRequest(ctx context.Context, fromUsername string, toUsername string) (bool, error) {
 	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.RemoveFollowRequest")
 	defer span.End()
 	return repo.RemoveFollow(ctx, fromUsername, toUsername, fmt.Sprintf(removeQuery, "FOLLOWS_REQUEST"))
 }
-func (repo *RepositoryNeo4j) RemoveFollow(ctx context.Context, fromUsername string, toUsername string, query string) error {
+
+// RemoveFollow Runs query and returns whether it deleted a relationship.
+func (repo *RepositoryNeo4j) RemoveFollow(ctx context.Context, fromUsername string, toUsername string, query string) (bool, error) {
 
 	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.RemoveFollow")
 	defer span.End()
 	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
 
 	defer session.Close()
-	_, er := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
-		_, err := tx.Run(query, map[string]interface{}{"from": fromUsername, "to": toUsername})
+	rez, er := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run(query, map[string]interface{}{"from": fromUsername, "to": toUsername, "timestamp": time.Now().UnixMilli()})
 		if err != nil {
 			log.Println(err)
 			return nil, err
 		}
-		return nil, nil
+		summary, err := result.Consume()
+		if err != nil {
+			return nil, err
+		}
+		return summary.Counters().RelationshipsDeleted() > 0, nil
 	})
 	if er != nil {
 		span.SetStatus(codes.Error, er.Error())
-		return er
+		return false, er
 	}
-	return nil
+	return rez.(bool), nil
 }
 func (repo *RepositoryNeo4j) GetFollowing(ctx context.Context, username string) ([]model.User, error) {
 	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.GetFollowing")
@@ -199,6 +400,65 @@ func (repo *RepositoryNeo4j) GetAllFollow(ctx context.Context, username string,
 	return rez.([]model.User), nil
 }
 
+func (repo *RepositoryNeo4j) GetFollowersPage(ctx context.Context, username string, after string, limit int) ([]model.User, error) {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.GetFollowersPage")
+	defer span.End()
+	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	rez, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		records, err := tx.Run("MATCH (u:User {username: $username})<-[:FOLLOWS]-(f:User) WHERE f.username > $after RETURN f.username as username, f.private as private ORDER BY f.username LIMIT $limit",
+			map[string]interface{}{"username": username, "after": after, "limit": limit})
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		var results []model.User
+		for records.Next() {
+			record := records.Record()
+			u, _ := record.Get("username")
+			p, _ := record.Get("private")
+			results = append(results, model.User{Username: u.(string), IsPrivate: p.(bool)})
+		}
+		return results, nil
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if rez == nil || rez.([]model.User) == nil {
+		return []model.User{}, nil
+	}
+	return rez.([]model.User), nil
+}
+
+func (repo *RepositoryNeo4j) GetFollowCounts(ctx context.Context, username string) (model.FollowCounts, error) {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.GetFollowCounts")
+	defer span.End()
+	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	rez, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run("MATCH (u:User {username: $username}) RETURN size((u)<-[:FOLLOWS]-()) as followers, size((u)-[:FOLLOWS]->()) as following", map[string]interface{}{"username": username})
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if !result.Next() {
+			return nil, repository.ErrUserNotFound
+		}
+		record := result.Record()
+		followers, _ := record.Get("followers")
+		following, _ := record.Get("following")
+		return model.FollowCounts{Followers: followers.(int64), Following: following.(int64)}, nil
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return model.FollowCounts{}, err
+	}
+	return rez.(model.FollowCounts), nil
+}
+
 func (repo *RepositoryNeo4j) GetAllFollowRequests(ctx context.Context, username string) ([]model.User, error) {
 	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.GetAllFollowRequests")
 	defer span.End()
@@ -233,7 +493,7 @@ func (repo *RepositoryNeo4j) CheckIfFollowRequestExists(ctx context.Context, use
 	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
 	defer session.Close()
 
-	rez, _ := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+	rez, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run("MATCH (f:User {username: $from }), (t:User {username: $to}) RETURN EXISTS( (f)-[:FOLLOWS_REQUEST]->(t)) as rez", map[string]interface{}{"from": usernameFrom, "to": usernameTo})
 		if err != nil {
 			log.Println(err)
@@ -247,6 +507,10 @@ func (repo *RepositoryNeo4j) CheckIfFollowRequestExists(ctx context.Context, use
 		res, _ := r.Get("rez")
 		return res, nil
 	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return false, err
+	}
 
 	return rez.(bool), nil
 }
@@ -257,7 +521,7 @@ func (repo *RepositoryNeo4j) CheckIfFollowExists(ctx context.Context, usernameFr
 	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
 	defer session.Close()
 
-	rez, _ := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+	rez, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
 		result, err := tx.Run("MATCH (f:User {username: $from }), (t:User {username: $to}) RETURN EXISTS( (f)-[:FOLLOWS]->(t)) as rez", map[string]interface{}{"from": usernameFrom, "to": usernameTo})
 		if err != nil {
 			log.Println(err)
@@ -271,6 +535,10 @@ func (repo *RepositoryNeo4j) CheckIfFollowExists(ctx context.Context, usernameFr
 		res, _ := r.Get("rez")
 		return res, nil
 	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return false, err
+	}
 
 	return rez.(bool), nil
 }
@@ -279,7 +547,10 @@ func (repo *RepositoryNeo4j) CanAccessTweetOfAnotherUser(ctx context.Context, us
 	if usernameFromToken == usernameForAccess {
 		return true, nil
 	}
-	userForAccess, _ := repo.GetUser(ctx, usernameForAccess)
+	userForAccess, err := repo.GetUser(ctx, usernameForAccess)
+	if err != nil {
+		return false, err
+	}
 	if !userForAccess.IsPrivate {
 		return true, nil
 	}
@@ -287,50 +558,95 @@ func (repo *RepositoryNeo4j) CanAccessTweetOfAnotherUser(ctx context.Context, us
 	return repo.CheckIfFollowExists(ctx, usernameFromToken, usernameForAccess)
 
 }
-func (repo *RepositoryNeo4j) AcceptRejectFollowRequest(ctx context.Context, from string, to string, approved bool) error {
-	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.AcceptRejectFollowRequest")
+func (repo *RepositoryNeo4j) CheckVisibility(ctx context.Context, viewer string, usernames []string) (map[string]bool, error) {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.CheckVisibility")
 	defer span.End()
-	exists, _ := repo.CheckIfFollowRequestExists(ctx, from, to)
-	if !exists {
-		return nil
-	}
-	err := repo.RemoveFollowRequest(ctx, from, to)
-	if err != nil {
-		span.SetStatus(codes.Error, err.Error())
-		return err
-	}
-	if approved {
-		err := repo.SaveApprovedFollow(ctx, from, to)
+	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	rez, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		records, err := tx.Run(checkVisibilityQuery, map[string]interface{}{"viewer": viewer, "usernames": usernames})
 		if err != nil {
-			return err
+			log.Println(err)
+			return nil, err
 		}
-		return nil
+		results := make(map[string]bool, len(usernames))
+		for records.Next() {
+			record := records.Record()
+			u, _ := record.Get("username")
+			v, _ := record.Get("visible")
+			results[u.(string)] = v.(bool)
+		}
+		return results, nil
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
 	}
+	return rez.(map[string]bool), nil
+}
 
-	return nil
+func (repo *RepositoryNeo4j) AcceptRejectFollowRequest(ctx context.Context, from string, to string, approved bool) (bool, error) {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.AcceptRejectFollowRequest")
+	defer span.End()
+	if !approved {
+		return repo.RemoveFollowRequest(ctx, from, to)
+	}
+	// the request is replaced by a follow and its feed update in a single transaction
+	return repo.SaveFollow(ctx, from, to, acceptFollowRequestQuery)
 }
-func (repo *RepositoryNeo4j) UpdateUser(ctx context.Context, isPrivate bool, authUsername string) error {
+func (repo *RepositoryNeo4j) UpdateUser(ctx context.Context, isPrivate bool, authUsername string) (bool, []model.User, error) {
 	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.UpdateUser")
 	defer span.End()
 
+	type update struct {
+		changed  bool
+		approved []model.User
+	}
+
 	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
 
 	defer session.Close()
-	_, er := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
-		_, err := tx.Run("match (u:User {username:$username}) set u.private= $isPrivate", map[string]interface{}{"username": authUsername, "isPrivate": isPrivate})
+	rez, er := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		result, err := tx.Run("MATCH (u:User {username: $username})\nWITH u, coalesce(u.private, false) <> $isPrivate as changed\nSET u.private = $isPrivate\nRETURN changed", map[string]interface{}{"username": authUsername, "isPrivate": isPrivate})
 		if err != nil {
 			span.SetStatus(codes.Error, err.Error())
 			log.Println(err)
 			return nil, err
 		}
-		return nil, nil
+		if !result.Next() {
+			return nil, repository.ErrUserNotFound
+		}
+		changed, _ := result.Record().Get("changed")
+		u := update{changed: changed.(bool)}
+		if isPrivate || !u.changed {
+			return u, nil
+		}
+
+		records, err := tx.Run(approveAllFollowRequestsQuery, map[string]interface{}{"username": authUsername, "timestamp": time.Now().UnixMilli()})
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			log.Println(err)
+			return nil, err
+		}
+		for records.Next() {
+			record := records.Record()
+			username, _ := record.Get("username")
+			private, _ := record.Get("private")
+			u.approved = append(u.approved, model.User{Username: username.(string), IsPrivate: private.(bool)})
+		}
+		return u, nil
 	})
 
 	if er != nil {
 		span.SetStatus(codes.Error, er.Error())
-		return er
+		return false, nil, er
 	}
-	return nil
+	u := rez.(update)
+	if u.approved == nil {
+		u.approved = []model.User{}
+	}
+	return u.changed, u.approved, nil
 }
 func (repo *RepositoryNeo4j) GetRecommendationsProfile(ctx context.Context, username string) ([]model.User, error) {
 	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.GetRecommendationsProfile")
@@ -386,3 +702,350 @@ func (repo *RepositoryNeo4j) GetAllUsersNotFollowedByUser(ctx context.Context, u
 	}
 	return rez.([]model.User), nil
 }
+
+func (repo *RepositoryNeo4j) GetFollowerGrowth(ctx context.Context, username string, from time.Time, to time.Time, interval time.Duration) ([]model.FollowerGrowth, int64, error) {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.GetFollowerGrowth")
+	defer span.End()
+	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	type growth struct {
+		buckets   []model.FollowerGrowth
+		followers int64
+	}
+
+	rez, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		params := map[string]interface{}{"username": username, "from": from.UnixMilli(), "to": to.UnixMilli(), "interval": interval.Milliseconds()}
+		records, err := tx.Run("MATCH (e:FollowEvent {username: $username}) WHERE e.timestamp >= $from AND e.timestamp < $to WITH e.timestamp - e.timestamp % $interval AS bucket, e.gained AS g RETURN bucket, sum(CASE WHEN g THEN 1 ELSE 0 END) AS gained, sum(CASE WHEN g THEN 0 ELSE 1 END) AS lost ORDER BY bucket", params)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		g := growth{buckets: []model.FollowerGrowth{}}
+		for records.Next() {
+			record := records.Record()
+			b, _ := record.Get("bucket")
+			gained, _ := record.Get("gained")
+			lost, _ := record.Get("lost")
+			g.buckets = append(g.buckets, model.FollowerGrowth{Timestamp: time.UnixMilli(b.(int64)).UTC(), Gained: gained.(int64), Lost: lost.(int64)})
+		}
+
+		// the count at to is the current count without the changes since
+		result, err := tx.Run("OPTIONAL MATCH (u:User {username: $username})<-[:FOLLOWS]-(f:User) WITH count(f) as followers OPTIONAL MATCH (e:FollowEvent {username: $username}) WHERE e.timestamp >= $to RETURN followers - sum(CASE WHEN e IS NULL THEN 0 WHEN e.gained THEN 1 ELSE -1 END) as followers", params)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		record, err := result.Single()
+		if err != nil {
+			return nil, err
+		}
+		followers, _ := record.Get("followers")
+		g.followers = followers.(int64)
+		return g, nil
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, 0, err
+	}
+	g := rez.(growth)
+	return g.buckets, g.followers, nil
+}
+
+func (repo *RepositoryNeo4j) ExportGraph(ctx context.Context, username string, depth int, userFn func(model.User) error, followFn func(model.Follow) error) error {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.ExportGraph")
+	defer span.End()
+
+	usersQuery := "MATCH (u:User) RETURN u.username as username, u.private as private"
+	followsQuery := "MATCH (f:User)-[r:FOLLOWS|FOLLOWS_REQUEST]->(t:User) RETURN f.username as from, t.username as to, type(r) = 'FOLLOWS_REQUEST' as request"
+	if username != "" {
+		usersQuery = fmt.Sprintf("MATCH (:User {username: $username})-[:FOLLOWS*0..%d]-(u:User) RETURN DISTINCT u.username as username, u.private as private", depth)
+		followsQuery = fmt.Sprintf("MATCH (:User {username: $username})-[:FOLLOWS*0..%d]-(u:User) WITH collect(DISTINCT u) as users UNWIND users as f MATCH (f)-[r:FOLLOWS|FOLLOWS_REQUEST]->(t:User) WHERE t IN users RETURN f.username as from, t.username as to, type(r) = 'FOLLOWS_REQUEST' as request", depth)
+	}
+	params := map[string]interface{}{"username": username}
+
+	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	// an explicit transaction, unlike ReadTransaction it is never retried
+	tx, err := session.BeginTransaction()
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	defer tx.Close()
+
+	err = streamTx(tx, usersQuery, params, func(record *neo4j.Record) error {
+		u, _ := record.Get("username")
+		p, _ := record.Get("private")
+		return userFn(model.User{Username: u.(string), IsPrivate: p.(bool)})
+	})
+	if err == nil {
+		err = streamTx(tx, followsQuery, params, func(record *neo4j.Record) error {
+			f, _ := record.Get("from")
+			t, _ := record.Get("to")
+			r, _ := record.Get("request")
+			return followFn(model.Follow{From: f.(string), To: t.(string), Request: r.(bool)})
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (repo *RepositoryNeo4j) ReadGraph(ctx context.Context, userFn func(model.User) error, followFn func(model.Follow) error) error {
+	readCtx, span := repo.tracer.Start(ctx, "RepositoryNeo4j.ReadGraph")
+	defer span.End()
+
+	err := repo.ExportGraph(readCtx, "", 0, userFn, followFn)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+func streamTx(tx neo4j.Transaction, query string, params map[string]interface{}, fn func(record *neo4j.Record) error) error {
+	result, err := tx.Run(query, params)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	for result.Next() {
+		if err := fn(result.Record()); err != nil {
+			return err
+		}
+	}
+	return result.Err()
+}
+
+func (repo *RepositoryNeo4j) GetEgoNetwork(ctx context.Context, viewer string, username string, depth int, limit int) ([]model.EgoNetworkUser, []model.Follow, error) {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.GetEgoNetwork")
+	defer span.End()
+	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	type egoNetwork struct {
+		users   []model.EgoNetworkUser
+		follows []model.Follow
+	}
+
+	rez, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		var network egoNetwork
+
+		records, err := tx.Run(egoQuery, map[string]interface{}{"username": username, "viewer": viewer})
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if !records.Next() {
+			return network, nil
+		}
+		p, _ := records.Record().Get("private")
+		v, _ := records.Record().Get("visible")
+		network.users = append(network.users, model.EgoNetworkUser{User: model.User{Username: username, IsPrivate: p.(bool)}})
+		usernames := []string{username}
+
+		// one hop at a time, so every user is read once however many paths lead to it
+		var frontier []string
+		if v.(bool) {
+			frontier = usernames
+		}
+		for d := 1; d <= depth && len(frontier) > 0 && len(network.users) < limit; d++ {
+			records, err = tx.Run(egoNeighboursQuery, map[string]interface{}{"frontier": frontier, "seen": usernames, "viewer": viewer, "limit": limit - len(network.users)})
+			if err != nil {
+				log.Println(err)
+				return nil, err
+			}
+			frontier = nil
+			for records.Next() {
+				record := records.Record()
+				u, _ := record.Get("username")
+				p, _ := record.Get("private")
+				network.users = append(network.users, model.EgoNetworkUser{User: model.User{Username: u.(string), IsPrivate: p.(bool)}, Depth: d})
+				frontier = append(frontier, u.(string))
+			}
+			usernames = append(usernames, frontier...)
+		}
+
+		records, err = tx.Run("MATCH (f:User)-[:FOLLOWS]->(t:User) WHERE f.username IN $usernames AND t.username IN $usernames RETURN f.username as from, t.username as to",
+			map[string]interface{}{"usernames": usernames})
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		for records.Next() {
+			record := records.Record()
+			f, _ := record.Get("from")
+			t, _ := record.Get("to")
+			network.follows = append(network.follows, model.Follow{From: f.(string), To: t.(string)})
+		}
+		return network, nil
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, nil, err
+	}
+
+	network := rez.(egoNetwork)
+	return network.users, network.follows, nil
+}
+
+func (repo *RepositoryNeo4j) GetUsers(ctx context.Context, usernames []string) ([]model.User, error) {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.GetUsers")
+	defer span.End()
+	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close()
+
+	rez, err := session.ReadTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		records, err := tx.Run("MATCH (u:User) WHERE u.username IN $usernames RETURN u.username as username, u.private as private", map[string]interface{}{"usernames": usernames})
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		var results []model.User
+		for records.Next() {
+			record := records.Record()
+			u, _ := record.Get("username")
+			p, _ := record.Get("private")
+			results = append(results, model.User{Username: u.(string), IsPrivate: p.(bool)})
+		}
+		return results, nil
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if rez == nil || rez.([]model.User) == nil {
+		return []model.User{}, nil
+	}
+	return rez.([]model.User), nil
+}
+
+func (repo *RepositoryNeo4j) SaveFollows(ctx context.Context, follows []model.Follow, dryRun bool) ([]bool, error) {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.SaveFollows")
+	defer span.End()
+
+	var approved, requests []interface{}
+	for i, follow := range follows {
+		row := map[string]interface{}{"i": i, "from": follow.From, "to": follow.To}
+		if follow.Request {
+			requests = append(requests, row)
+		} else {
+			approved = append(approved, row)
+		}
+	}
+
+	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+
+	// an explicit transaction, so a dry run can be rolled back
+	tx, err := session.BeginTransaction()
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	defer tx.Close()
+
+	// the queries return the index of every row they saved
+	saved := make([]bool, len(follows))
+	markSaved := func(record *neo4j.Record) error {
+		i, _ := record.Get("i")
+		saved[i.(int64)] = true
+		return nil
+	}
+	if len(approved) > 0 {
+		err = streamTx(tx, batchFollowQuery, map[string]interface{}{"follows": approved, "timestamp": time.Now().UnixMilli()}, markSaved)
+	}
+	if err == nil && len(requests) > 0 {
+		err = streamTx(tx, batchFollowRequestQuery, map[string]interface{}{"follows": requests}, markSaved)
+	}
+	if err == nil && !dryRun {
+		err = tx.Commit()
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return saved, nil
+}
+
+func (repo *RepositoryNeo4j) ClaimOutboxMessages(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.OutboxMessage, error) {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.ClaimOutboxMessages")
+	defer span.End()
+	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+
+	rez, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		records, err := tx.Run("MATCH (o:Outbox {status: 'pending'}) WHERE o.nextAttemptAt <= $now AND NOT EXISTS { MATCH (p:Outbox {status: 'pending', from: o.from, to: o.to}) WHERE p.createdAt < o.createdAt OR (p.createdAt = o.createdAt AND p.id < o.id) } WITH o ORDER BY o.nextAttemptAt LIMIT $limit SET o.nextAttemptAt = $leaseUntil RETURN o.id as id, o.kind as kind, o.from as from, o.to as to, o.attempts as attempts, o.createdAt as createdAt",
+			map[string]interface{}{"now": now.UnixMilli(), "leaseUntil": now.Add(lease).UnixMilli(), "limit": limit})
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		var results []model.OutboxMessage
+		for records.Next() {
+			record := records.Record()
+			id, _ := record.Get("id")
+			kind, _ := record.Get("kind")
+			f, _ := record.Get("from")
+			t, _ := record.Get("to")
+			attempts, _ := record.Get("attempts")
+			createdAt, _ := record.Get("createdAt")
+			results = append(results, model.OutboxMessage{
+				ID:        id.(string),
+				Kind:      kind.(string),
+				From:      f.(string),
+				To:        t.(string),
+				Attempts:  int(attempts.(int64)),
+				Status:    model.OutboxPending,
+				CreatedAt: time.UnixMilli(createdAt.(int64)),
+			})
+		}
+		return results, nil
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if rez == nil || rez.([]model.OutboxMessage) == nil {
+		return []model.OutboxMessage{}, nil
+	}
+	return rez.([]model.OutboxMessage), nil
+}
+
+func (repo *RepositoryNeo4j) UpdateOutboxMessage(ctx context.Context, msg model.OutboxMessage) error {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.UpdateOutboxMessage")
+	defer span.End()
+	return repo.writeOutbox(span, "MATCH (o:Outbox {id: $id}) SET o.status = $status, o.attempts = $attempts, o.nextAttemptAt = $nextAttemptAt, o.lastError = $lastError",
+		map[string]interface{}{"id": msg.ID, "status": msg.Status, "attempts": msg.Attempts, "nextAttemptAt": msg.NextAttemptAt.UnixMilli(), "lastError": msg.LastError})
+}
+
+func (repo *RepositoryNeo4j) DeleteOutboxMessage(ctx context.Context, id string) error {
+	_, span := repo.tracer.Start(ctx, "RepositoryNeo4j.DeleteOutboxMessage")
+	defer span.End()
+	return repo.writeOutbox(span, "MATCH (o:Outbox {id: $id}) DELETE o", map[string]interface{}{"id": id})
+}
+
+func (repo *RepositoryNeo4j) writeOutbox(span trace.Span, query string, params map[string]interface{}) error {
+	session := repo.driver.NewSession(neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+	defer session.Close()
+	_, err := session.WriteTransaction(func(tx neo4j.Transaction) (interface{}, error) {
+		_, err := tx.Run(query, params)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		return nil, nil
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
@@ -2,24 +2,84 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"social-graph/model"
+	"time"
 )
 
+var ErrUserNotFound = errors.New("user not found")
+
 type SocialGraphRepository interface {
 	CreateNewUser(ctx context.Context, username string, isPrivate bool) error
-	SaveApprovedFollow(ctx context.Context, fromUsername string, toUsername string) error
-	RemoveApprovedFollow(ctx context.Context, fromUsername string, toUsername string) error
-	RemoveFollowRequest(ctx context.Context, fromUsername string, toUsername string) error
-	SaveFollowRequest(ctx context.Context, fromUsername string, toUsername string) error
+	// DeleteUser Deletes the user with all its follows and follow requests, and queues removing
+	// its tweets from the feeds of its followers. The users it followed get a lost follower
+	// event. Deleting a missing user does nothing.
+	DeleteUser(ctx context.Context, username string) error
+	// RegisterUser Creates the user of a registration, marked as registering since now until
+	// ConfirmRegistration. An existing user is left unchanged.
+	RegisterUser(ctx context.Context, username string, isPrivate bool) error
+	ConfirmRegistration(ctx context.Context, username string) error
+	// RollbackRegistration Deletes the user like DeleteUser, only if it was created by RegisterUser
+	// at or after since and is still registering. Registrations started before since count as
+	// complete, so the user is kept. Returns whether it was deleted.
+	RollbackRegistration(ctx context.Context, username string, since time.Time) (bool, error)
+	// SaveApprovedFollow Returns whether the follow was created, false when it already existed.
+	SaveApprovedFollow(ctx context.Context, fromUsername string, toUsername string) (bool, error)
+	// RemoveApprovedFollow Returns whether a follow was deleted.
+	RemoveApprovedFollow(ctx context.Context, fromUsername string, toUsername string) (bool, error)
+	// RemoveFollowRequest Returns whether a follow request was deleted.
+	RemoveFollowRequest(ctx context.Context, fromUsername string, toUsername string) (bool, error)
+	// SaveFollowRequest Returns whether the follow request was created, false when it or the
+	// follow already existed.
+	SaveFollowRequest(ctx context.Context, fromUsername string, toUsername string) (bool, error)
 	GetFollowing(ctx context.Context, username string) ([]model.User, error)
 	GetFollowers(ctx context.Context, username string) ([]model.User, error)
+	// GetFollowersPage Returns at most limit followers of username ordered by username,
+	// starting after the username after.
+	GetFollowersPage(ctx context.Context, username string, after string, limit int) ([]model.User, error)
+	// GetFollowCounts Returns ErrUserNotFound when username doesn't exist.
+	GetFollowCounts(ctx context.Context, username string) (model.FollowCounts, error)
 	CheckIfFollowExists(ctx context.Context, from string, to string) (bool, error)
-	AcceptRejectFollowRequest(ctx context.Context, from string, to string, approved bool) error
+	// AcceptRejectFollowRequest Returns whether the follow request was rejected, or accepted into a
+	// new follow.
+	AcceptRejectFollowRequest(ctx context.Context, from string, to string, approved bool) (bool, error)
 	GetUser(ctx context.Context, username string) (user model.User, err error)
+	// GetUsers Returns the users that exist out of usernames.
+	GetUsers(ctx context.Context, usernames []string) ([]model.User, error)
+	// SaveFollows Saves follows and follow requests in a single transaction and returns for every
+	// one of follows whether it was saved. Existing follows and follow requests are skipped.
+	// When dryRun is set the transaction is rolled back.
+	SaveFollows(ctx context.Context, follows []model.Follow, dryRun bool) ([]bool, error)
 	CheckIfFollowRequestExists(ctx context.Context, from string, to string) (bool, error)
 	GetAllFollowRequests(ctx context.Context, username string) ([]model.User, error)
 	GetAllUsersNotFollowedByUser(ctx context.Context, username string) ([]model.User, error)
 	GetRecommendationsProfile(ctx context.Context, username string) ([]model.User, error)
 	CanAccessTweetOfAnotherUser(ctx context.Context, usernameFromToken string, usernameForAccess string) (bool, error)
-	UpdateUser(ctx context.Context, isPrivate bool, authUsername string) error
+	// CheckVisibility Returns for every one of usernames whether viewer can access its tweets,
+	// in a single query. Users that don't exist are not visible.
+	CheckVisibility(ctx context.Context, viewer string, usernames []string) (map[string]bool, error)
+	// UpdateUser Changes the privacy of the user and returns whether it changed. A public user
+	// can't have follow requests, so in the same transaction they are all approved and returned.
+	// Returns ErrUserNotFound when authUsername doesn't exist.
+	UpdateUser(ctx context.Context, isPrivate bool, authUsername string) (bool, []model.User, error)
+	// ReadGraph Streams all users, then all follows and follow requests, read in one transaction.
+	ReadGraph(ctx context.Context, userFn func(model.User) error, followFn func(model.Follow) error) error
+	// RestoreFollow Saves the FOLLOWS edge only, without the follow event and feed update of a new follow.
+	RestoreFollow(ctx context.Context, fromUsername string, toUsername string) error
+	// ExportGraph Streams all users, or the users within depth FOLLOWS hops of username when it is
+	// not empty, then the follows and follow requests between them, read in one transaction.
+	ExportGraph(ctx context.Context, username string, depth int, userFn func(model.User) error, followFn func(model.Follow) error) error
+	// GetEgoNetwork Returns at most limit users within depth FOLLOWS hops of username, nearest first,
+	// and the FOLLOWS edges between them. Only users viewer can access are expanded and returned,
+	// except username itself. Returns no users when username doesn't exist.
+	GetEgoNetwork(ctx context.Context, viewer string, username string, depth int, limit int) ([]model.EgoNetworkUser, []model.Follow, error)
+	// ClaimOutboxMessages Returns pending outbox messages due at now and hides them from other claims for lease.
+	// Only the oldest pending message of each follow (from, to) is claimed, so a feed.remove waits until an
+	// earlier feed.update of the same follow is delivered or dead, even while that one is leased or backing off.
+	ClaimOutboxMessages(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]model.OutboxMessage, error)
+	UpdateOutboxMessage(ctx context.Context, msg model.OutboxMessage) error
+	DeleteOutboxMessage(ctx context.Context, id string) error
+	// GetFollowerGrowth Returns the followers gained and lost per interval in [from, to), only
+	// the intervals with any, and the follower count at to, read in one transaction.
+	GetFollowerGrowth(ctx context.Context, username string, from time.Time, to time.Time, interval time.Duration) ([]model.FollowerGrowth, int64, error)
 }
@@ -0,0 +1,174 @@
+package resilience
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	Closed State = iota
+	Open
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Breaker{}
+)
+
+func init() {
+	expvar.Publish("circuit_breakers", expvar.Func(func() interface{} {
+		stats := map[string]BreakerStats{}
+		for _, b := range Breakers() {
+			stats[b.name] = b.Stats()
+		}
+		return stats
+	}))
+}
+
+// Breakers Returns every breaker created by NewBreaker.
+func Breakers() []*Breaker {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	breakers := make([]*Breaker, 0, len(registry))
+	for _, b := range registry {
+		breakers = append(breakers, b)
+	}
+	return breakers
+}
+
+// Breaker Circuit breaker that opens after FailureThreshold consecutive failures. While open,
+// calls are rejected until OpenTimeout passes, then a single probe call is let through in the
+// half-open state: its success closes the breaker and its failure opens it again.
+type Breaker struct {
+	name             string
+	failureThreshold int
+	openTimeout      time.Duration
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+	rejected int64
+	opened   int64
+}
+
+type BreakerStats struct {
+	State    string `json:"state"`
+	Failures int    `json:"failures"`
+	Rejected int64  `json:"rejected"`
+	Opened   int64  `json:"opened"`
+}
+
+// NewBreaker Creates a breaker and registers it under name for metrics and health checks.
+func NewBreaker(name string, failureThreshold int, openTimeout time.Duration) *Breaker {
+	b := &Breaker{
+		name:             name,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+	}
+
+	registryMu.Lock()
+	registry[name] = b
+	registryMu.Unlock()
+
+	return b
+}
+
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState()
+}
+
+func (b *Breaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BreakerStats{
+		State:    b.currentState().String(),
+		Failures: b.failures,
+		Rejected: b.rejected,
+		Opened:   b.opened,
+	}
+}
+
+// Allow Returns ErrBreakerOpen if the call must not be made, otherwise the outcome
+// of the call has to be reported with Record or Release.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.currentState() {
+	case Open:
+		b.rejected++
+		return ErrBreakerOpen
+	case HalfOpen:
+		if b.probing {
+			b.rejected++
+			return ErrBreakerOpen
+		}
+		b.state = HalfOpen
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *Breaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.state = Closed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+
+	b.failures++
+	if b.state == HalfOpen || b.failures >= b.failureThreshold {
+		b.state = Open
+		b.openedAt = time.Now()
+		b.probing = false
+		b.opened++
+	}
+}
+
+// Release Reports a call allowed by Allow whose outcome says nothing about the target, like a
+// call canceled by the caller. It neither closes nor opens the breaker, a half-open breaker
+// lets the next call probe.
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// currentState Open breakers become half-open once openTimeout has passed.
+func (b *Breaker) currentState() State {
+	if b.state == Open && time.Since(b.openedAt) >= b.openTimeout {
+		return HalfOpen
+	}
+	return b.state
+}
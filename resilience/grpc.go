@@ -0,0 +1,97 @@
+package resilience
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math/rand"
+	"time"
+)
+
+// Policy How outbound calls are bounded and retried. Only the methods listed in Idempotent are
+// retried, every other call is made once and the callers that need it retry on their own terms,
+// like the outbox does for feed updates.
+type Policy struct {
+	// Timeout Deadline of a single attempt, unless the caller's deadline is earlier.
+	Timeout time.Duration
+	// MaxRetries Retries after the first attempt, only for methods listed in Idempotent.
+	MaxRetries  int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Idempotent Full method names, like /grpc.health.v1.Health/Check, safe to call again.
+	Idempotent map[string]bool
+}
+
+// UnaryClientInterceptor Applies policy to every call and guards the target with breaker. Every
+// attempt goes through the breaker, so retries stop as soon as it opens.
+func UnaryClientInterceptor(breaker *Breaker, policy Policy) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		retries := 0
+		if policy.Idempotent[method] {
+			retries = policy.MaxRetries
+		}
+
+		for attempt := 0; ; attempt++ {
+			if err := breaker.Allow(); err != nil {
+				return status.Error(codes.Unavailable, breaker.Name()+": "+err.Error())
+			}
+
+			attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+			if policy.Timeout > 0 {
+				attemptCtx, cancel = context.WithTimeout(ctx, policy.Timeout)
+			}
+			err := invoker(attemptCtx, method, req, reply, cc, opts...)
+			cancel()
+
+			// a call given up by the caller says nothing about the target
+			if status.Code(err) == codes.Canceled || ctx.Err() != nil {
+				breaker.Release()
+				return err
+			}
+			failed := isFailure(err)
+			breaker.Record(!failed)
+			if !failed || attempt >= retries || !isRetryable(err) {
+				return err
+			}
+
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(jitter(policy.BaseBackoff, policy.MaxBackoff, attempt)):
+			}
+		}
+	}
+}
+
+// isFailure Whether err means the target is unhealthy. Errors caused by the request itself
+// don't count against the breaker.
+func isFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.OK, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.PermissionDenied,
+		codes.Unauthenticated, codes.FailedPrecondition, codes.OutOfRange, codes.Unimplemented:
+		return false
+	}
+	return true
+}
+
+// isRetryable Whether another attempt of an idempotent call may succeed.
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// jitter Random delay up to the exponential backoff of attempt, so that clients retrying the
+// same failure don't retry in lockstep.
+func jitter(base time.Duration, max time.Duration, attempt int) time.Duration {
+	backoff := max
+	if attempt < 32 {
+		if b := base << attempt; b > 0 && b < max {
+			backoff = b
+		}
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1))
+}
@@ -0,0 +1,305 @@
+package resilience
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+const openTimeout = 20 * time.Millisecond
+
+func TestBreaker(t *testing.T) {
+	type step struct {
+		// allow Whether Allow is expected to let the call through, its outcome is then reported.
+		allow bool
+		// outcome success, failure or release
+		outcome string
+		// wait Sleep past openTimeout before the step.
+		wait  bool
+		state State
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "opens after threshold consecutive failures",
+			steps: []step{
+				{allow: true, outcome: "failure", state: Closed},
+				{allow: true, outcome: "failure", state: Closed},
+				{allow: true, outcome: "failure", state: Open},
+				{allow: false, state: Open},
+			},
+		},
+		{
+			name: "success resets the failure count",
+			steps: []step{
+				{allow: true, outcome: "failure", state: Closed},
+				{allow: true, outcome: "failure", state: Closed},
+				{allow: true, outcome: "success", state: Closed},
+				{allow: true, outcome: "failure", state: Closed},
+				{allow: true, outcome: "failure", state: Closed},
+			},
+		},
+		{
+			name: "probe success closes",
+			steps: []step{
+				{allow: true, outcome: "failure"},
+				{allow: true, outcome: "failure"},
+				{allow: true, outcome: "failure", state: Open},
+				{wait: true, allow: true, outcome: "success", state: Closed},
+				{allow: true, outcome: "failure", state: Closed},
+			},
+		},
+		{
+			name: "probe failure opens again",
+			steps: []step{
+				{allow: true, outcome: "failure"},
+				{allow: true, outcome: "failure"},
+				{allow: true, outcome: "failure", state: Open},
+				{wait: true, allow: true, outcome: "failure", state: Open},
+				{allow: false, state: Open},
+			},
+		},
+		{
+			name: "released probe is neutral",
+			steps: []step{
+				{allow: true, outcome: "failure"},
+				{allow: true, outcome: "failure"},
+				{allow: true, outcome: "failure", state: Open},
+				{wait: true, allow: true, outcome: "release", state: HalfOpen},
+				{allow: true, outcome: "success", state: Closed},
+			},
+		},
+		{
+			name: "released calls don't count",
+			steps: []step{
+				{allow: true, outcome: "failure"},
+				{allow: true, outcome: "failure"},
+				{allow: true, outcome: "release", state: Closed},
+				{allow: true, outcome: "release", state: Closed},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreaker(t.Name(), 3, openTimeout)
+			for i, s := range tt.steps {
+				if s.wait {
+					time.Sleep(2 * openTimeout)
+				}
+				err := b.Allow()
+				if (err == nil) != s.allow {
+					t.Fatalf("step %d: got %v, want allowed %t", i, err, s.allow)
+				}
+				switch s.outcome {
+				case "success":
+					b.Record(true)
+				case "failure":
+					b.Record(false)
+				case "release":
+					b.Release()
+				}
+				if got := b.State(); got != s.state {
+					t.Fatalf("step %d: got state %s, want %s", i, got, s.state)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerAllowsOneProbe(t *testing.T) {
+	b := NewBreaker(t.Name(), 1, openTimeout)
+	b.Allow()
+	b.Record(false)
+	time.Sleep(2 * openTimeout)
+
+	if err := b.Allow(); err != nil {
+		t.Fatalf("probe rejected: %v", err)
+	}
+	if err := b.Allow(); err != ErrBreakerOpen {
+		t.Fatalf("second call during the probe got %v, want %v", err, ErrBreakerOpen)
+	}
+	if stats := b.Stats(); stats.Rejected != 1 || stats.Opened != 1 || stats.State != "half-open" {
+		t.Errorf("got stats %+v", stats)
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	tests := []struct {
+		name string
+		// err Returned by the invoker.
+		err error
+		// cancel Cancels the caller's context before the call.
+		cancel bool
+		state  State
+	}{
+		{name: "ok", state: Closed},
+		{name: "unavailable", err: status.Error(codes.Unavailable, "down"), state: Open},
+		{name: "deadline exceeded", err: status.Error(codes.DeadlineExceeded, "slow"), state: Open},
+		{name: "internal", err: status.Error(codes.Internal, "bug"), state: Open},
+		{name: "invalid argument", err: status.Error(codes.InvalidArgument, "bad request"), state: Closed},
+		{name: "not found", err: status.Error(codes.NotFound, "no user"), state: Closed},
+		{name: "canceled", err: status.Error(codes.Canceled, "canceled"), state: Closed},
+		{name: "caller gave up", err: status.Error(codes.DeadlineExceeded, "slow"), cancel: true, state: Closed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreaker(t.Name(), 1, time.Minute)
+			interceptor := UnaryClientInterceptor(b, Policy{Timeout: time.Second})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+
+			calls := 0
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				calls++
+				if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Second {
+					t.Errorf("call deadline %v is not bounded by the policy timeout", deadline)
+				}
+				return tt.err
+			}
+
+			err := interceptor(ctx, "/tweet.TweetService/UpdateFeed", nil, nil, nil, invoker)
+			if err != tt.err {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+			if calls != 1 {
+				t.Errorf("invoked %d times, non-idempotent calls must not be retried", calls)
+			}
+			if got := b.State(); got != tt.state {
+				t.Errorf("got state %s, want %s", got, tt.state)
+			}
+		})
+	}
+}
+
+func TestUnaryClientInterceptorRejectsWhileOpen(t *testing.T) {
+	b := NewBreaker(t.Name(), 1, time.Minute)
+	b.Allow()
+	b.Record(false)
+
+	invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		t.Error("call made while the breaker is open")
+		return nil
+	}
+	err := UnaryClientInterceptor(b, Policy{})(context.Background(), "/tweet.TweetService/UpdateFeed", nil, nil, nil, invoker)
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v, want Unavailable", err)
+	}
+}
+
+func TestUnaryClientInterceptorRetries(t *testing.T) {
+	const method = "/grpc.health.v1.Health/Check"
+	unavailable := status.Error(codes.Unavailable, "down")
+	notFound := status.Error(codes.NotFound, "no user")
+
+	tests := []struct {
+		name   string
+		method string
+		// errs Returned by the invoker on each attempt, nil once they run out.
+		errs      []error
+		threshold int
+		err       error
+		// rejected The last attempt is expected to be rejected by the breaker.
+		rejected bool
+		calls    int
+	}{
+		{name: "succeeds on retry", method: method, errs: []error{unavailable}, threshold: 5, calls: 2},
+		{name: "gives up after max retries", method: method, errs: []error{unavailable, unavailable, unavailable, unavailable}, threshold: 5, err: unavailable, calls: 3},
+		{name: "request errors are not retried", method: method, errs: []error{notFound}, threshold: 5, err: notFound, calls: 1},
+		{name: "not idempotent", method: "/tweet.TweetService/UpdateFeed", errs: []error{unavailable}, threshold: 5, err: unavailable, calls: 1},
+		{name: "stops when the breaker opens", method: method, errs: []error{unavailable, unavailable}, threshold: 1, rejected: true, calls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreaker(t.Name(), tt.threshold, time.Minute)
+			interceptor := UnaryClientInterceptor(b, Policy{
+				MaxRetries:  2,
+				BaseBackoff: time.Millisecond,
+				MaxBackoff:  5 * time.Millisecond,
+				Idempotent:  map[string]bool{method: true},
+			})
+
+			calls := 0
+			invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+				calls++
+				if calls > len(tt.errs) {
+					return nil
+				}
+				return tt.errs[calls-1]
+			}
+
+			err := interceptor(context.Background(), tt.method, nil, nil, nil, invoker)
+			if tt.rejected {
+				if status.Code(err) != codes.Unavailable || err == unavailable {
+					t.Errorf("got %v, want the breaker to reject the retry", err)
+				}
+			} else if err != tt.err {
+				t.Errorf("got %v, want %v", err, tt.err)
+			}
+			if calls != tt.calls {
+				t.Errorf("invoked %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestUnaryClientInterceptorStopsRetryingWhenCallerGivesUp(t *testing.T) {
+	const method = "/grpc.health.v1.Health/Check"
+	b := NewBreaker(t.Name(), 5, time.Minute)
+	interceptor := UnaryClientInterceptor(b, Policy{
+		MaxRetries:  2,
+		BaseBackoff: time.Hour,
+		MaxBackoff:  time.Hour,
+		Idempotent:  map[string]bool{method: true},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	calls := 0
+	invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		calls++
+		return status.Error(codes.Unavailable, "down")
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- interceptor(ctx, method, nil, nil, nil, invoker)
+	}()
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("got %v, want the last attempt's error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("kept waiting to retry after the caller gave up")
+	}
+	if calls != 1 {
+		t.Errorf("invoked %d times, want 1", calls)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for attempt := 0; attempt < 40; attempt++ {
+		max := time.Second
+		if attempt < 4 {
+			max = 100 * time.Millisecond << attempt
+		}
+		for i := 0; i < 100; i++ {
+			if got := jitter(100*time.Millisecond, time.Second, attempt); got < 0 || got > max {
+				t.Fatalf("attempt %d waits %v, want at most %v", attempt, got, max)
+			}
+		}
+	}
+}
@@ -3,6 +3,8 @@ package saga
 const (
 	REGISTER_COMMAND = "register.reply"
 	REGISTER_REPLY   = "register.command"
+	DELETE_COMMAND   = "delete.command"
+	DELETE_REPLY     = "delete.reply"
 )
 
 type RegisterUserCommandType int8
@@ -24,6 +26,7 @@ const (
 	ProfileRollback
 	SocialGraphSuccess
 	SocialGraphFail
+	SocialGraphRollback
 )
 
 // User Combined data of business and ordinary user.
@@ -50,3 +53,26 @@ type RegisterUserReply struct {
 	Reply RegisterUserReplyType
 	User  NewUser
 }
+
+type DeleteUserCommandType int8
+
+const (
+	DeleteSocialGraph DeleteUserCommandType = iota
+)
+
+type DeleteUserReplyType int8
+
+const (
+	SocialGraphDeleteSuccess DeleteUserReplyType = iota
+	SocialGraphDeleteFail
+)
+
+type DeleteUserCommand struct {
+	Command  DeleteUserCommandType
+	Username string
+}
+
+type DeleteUserReply struct {
+	Reply    DeleteUserReplyType
+	Username string
+}
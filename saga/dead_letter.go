@@ -0,0 +1,194 @@
+package saga
+
+import (
+	"errors"
+	"expvar"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+	"log"
+	"time"
+)
+
+const (
+	// DEAD_LETTER Saga messages that can't be handled are published here with their original
+	// headers and payload, plus the Dead-Letter-* headers.
+	DEAD_LETTER        = "socialgraph.saga.deadletter"
+	DEAD_LETTER_STREAM = "SAGA_DEAD_LETTERS"
+
+	deadLetterSubjectHeader = "Dead-Letter-Subject"
+	deadLetterErrorHeader   = "Dead-Letter-Error"
+
+	// listTimeout Bounds reading the dead letters for List.
+	listTimeout = 5 * time.Second
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+var (
+	deadLetters         = expvar.NewMap("saga_dead_letters")
+	replayedDeadLetters = expvar.NewMap("saga_dead_letters_replayed")
+)
+
+type DeadLetter struct {
+	Sequence uint64    `json:"sequence"`
+	Subject  string    `json:"subject"`
+	Error    string    `json:"error"`
+	Time     time.Time `json:"time"`
+	Payload  string    `json:"payload"`
+}
+
+// DeadLetterQueue Keeps invalid saga messages in a JetStream stream until an admin replays them.
+type DeadLetterQueue struct {
+	tracer trace.Tracer
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+}
+
+func NewDeadLetterQueue(tracer trace.Tracer, connection *nats.Conn) (*DeadLetterQueue, error) {
+	js, err := connection.JetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = js.StreamInfo(DEAD_LETTER_STREAM)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:     DEAD_LETTER_STREAM,
+			Subjects: []string{DEAD_LETTER},
+			MaxAge:   30 * 24 * time.Hour,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeadLetterQueue{
+		tracer: tracer,
+		conn:   connection,
+		js:     js,
+	}, nil
+}
+
+// Publish Moves msg to the dead letter stream. If that fails msg is left unacknowledged
+// for redelivery, otherwise it is terminated.
+func (q *DeadLetterQueue) Publish(ctx context.Context, msg *nats.Msg, reason error) {
+	_, span := q.tracer.Start(ctx, "DeadLetterQueue.Publish")
+	defer span.End()
+
+	headers := nats.Header{}
+	for k, v := range msg.Header {
+		headers[k] = v
+	}
+	headers.Set(deadLetterSubjectHeader, msg.Subject)
+	headers.Set(deadLetterErrorHeader, reason.Error())
+
+	_, err := q.js.PublishMsg(&nats.Msg{
+		Subject: DEAD_LETTER,
+		Header:  headers,
+		Data:    msg.Data,
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		log.Printf("failed to dead letter message from %s: %v", msg.Subject, err)
+		return
+	}
+
+	deadLetters.Add(msg.Subject, 1)
+	log.Printf("dead lettered message from %s: %v", msg.Subject, reason)
+	msg.Term()
+}
+
+// List Returns up to limit of the oldest dead letters, read through an ordered consumer so
+// that replayed ones are skipped by the server.
+func (q *DeadLetterQueue) List(ctx context.Context, limit int) ([]DeadLetter, error) {
+	listCtx, span := q.tracer.Start(ctx, "DeadLetterQueue.List")
+	defer span.End()
+
+	info, err := q.js.StreamInfo(DEAD_LETTER_STREAM)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	letters := []DeadLetter{}
+	if info.State.Msgs == 0 || limit <= 0 {
+		return letters, nil
+	}
+
+	sub, err := q.js.SubscribeSync(DEAD_LETTER, nats.BindStream(DEAD_LETTER_STREAM), nats.OrderedConsumer(), nats.DeliverAll())
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	defer sub.Unsubscribe()
+
+	fetchCtx, cancel := context.WithTimeout(listCtx, listTimeout)
+	defer cancel()
+	for len(letters) < limit {
+		raw, err := sub.NextMsgWithContext(fetchCtx)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		meta, err := raw.Metadata()
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return nil, err
+		}
+		letters = append(letters, DeadLetter{
+			Sequence: meta.Sequence.Stream,
+			Subject:  raw.Header.Get(deadLetterSubjectHeader),
+			Error:    raw.Header.Get(deadLetterErrorHeader),
+			Time:     meta.Timestamp,
+			Payload:  string(raw.Data),
+		})
+		if meta.NumPending == 0 {
+			break
+		}
+	}
+	return letters, nil
+}
+
+// Replay Publishes the dead letter with sequence to its original subject and removes it.
+func (q *DeadLetterQueue) Replay(ctx context.Context, sequence uint64) error {
+	_, span := q.tracer.Start(ctx, "DeadLetterQueue.Replay")
+	defer span.End()
+
+	raw, err := q.js.GetMsg(DEAD_LETTER_STREAM, sequence)
+	if errors.Is(err, nats.ErrMsgNotFound) {
+		return ErrDeadLetterNotFound
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	subject := raw.Header.Get(deadLetterSubjectHeader)
+	headers := nats.Header{}
+	for k, v := range raw.Header {
+		headers[k] = v
+	}
+	headers.Del(deadLetterSubjectHeader)
+	headers.Del(deadLetterErrorHeader)
+
+	// the dead letter is deleted only once the stream of subject has stored the message
+	_, err = q.js.PublishMsg(&nats.Msg{
+		Subject: subject,
+		Header:  headers,
+		Data:    raw.Data,
+	}, nats.Context(ctx))
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	err = q.js.DeleteMsg(DEAD_LETTER_STREAM, sequence)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	replayedDeadLetters.Add(subject, 1)
+	return nil
+}
@@ -0,0 +1,120 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+	"time"
+)
+
+func TestDeadLetterQueueMalformedCommand(t *testing.T) {
+	s := runNATSServer(t)
+	tracer := trace.NewNoopTracerProvider().Tracer("test")
+	repo := newCountingRepository()
+
+	conn := connect(t, s)
+	dlq := newDeadLetterQueue(t, conn)
+	if _, err := NewRegisterUserHandler(tracer, conn, repo, dlq); err != nil {
+		t.Fatal(err)
+	}
+
+	replyConn := connect(t, s)
+	replies, err := replyConn.SubscribeSync(REGISTER_REPLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyConn.Flush()
+
+	publisher := connect(t, s)
+	if err := publisher.Publish(REGISTER_COMMAND, []byte("{not json")); err != nil {
+		t.Fatal(err)
+	}
+	unknown, _ := json.Marshal(RegisterUserCommand{Command: 42, User: NewUser{Username: "dave"}})
+	if err := publisher.Publish(REGISTER_COMMAND, unknown); err != nil {
+		t.Fatal(err)
+	}
+
+	var letters []DeadLetter
+	deadline := time.Now().Add(5 * time.Second)
+	for len(letters) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d dead letters, want 2", len(letters))
+		}
+		time.Sleep(50 * time.Millisecond)
+		letters, err = dlq.List(context.Background(), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, l := range letters {
+		if l.Subject != REGISTER_COMMAND || l.Error == "" {
+			t.Errorf("got dead letter %+v", l)
+		}
+	}
+	if letters[0].Payload != "{not json" {
+		t.Errorf("got payload %q", letters[0].Payload)
+	}
+
+	// a replayed message goes through the handler again
+	if err := dlq.Replay(context.Background(), letters[1].Sequence); err != nil {
+		t.Fatal(err)
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		letters, err = dlq.List(context.Background(), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(letters) == 2 && letters[1].Sequence > letters[0].Sequence+1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("replayed message not dead lettered again, got %+v", letters)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := dlq.Replay(context.Background(), 1000); err != ErrDeadLetterNotFound {
+		t.Errorf("got %v, want ErrDeadLetterNotFound", err)
+	}
+
+	if _, err := replies.NextMsg(200 * time.Millisecond); err == nil {
+		t.Error("got a reply to a malformed command")
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(repo.created) != 0 {
+		t.Errorf("created %v", repo.created)
+	}
+}
+
+func TestDeadLetterQueueReplayKeepsUnstoredMessage(t *testing.T) {
+	s := runNATSServer(t)
+	conn := connect(t, s)
+	dlq := newDeadLetterQueue(t, conn)
+
+	// no stream captures the original subject, so the replay can't be acknowledged
+	dlq.Publish(context.Background(), &nats.Msg{Subject: "unknown.command", Data: []byte("{}")}, errors.New("unknown"))
+	letters, err := dlq.List(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 {
+		t.Fatalf("got dead letters %+v", letters)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := dlq.Replay(ctx, letters[0].Sequence); err == nil {
+		t.Fatal("replayed a message nothing stored")
+	}
+	letters, err = dlq.List(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 {
+		t.Errorf("the dead letter was deleted, got %+v", letters)
+	}
+}
@@ -0,0 +1,176 @@
+package saga
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/context"
+	"log"
+	"social-graph/messaging"
+	"social-graph/repository"
+	"social-graph/tracing"
+)
+
+const (
+	DELETE_STREAM   = "DELETE_COMMANDS"
+	DELETE_CONSUMER = "social-graph-delete"
+	// deleteSaga Prefix of the processed keys of delete commands.
+	deleteSaga = "delete"
+)
+
+// DeleteUserHandler Participant of the account deletion saga, removes the user from the graph.
+type DeleteUserHandler struct {
+	tracer    trace.Tracer
+	conn      *nats.Conn
+	processed nats.KeyValue
+	repo      repository.SocialGraphRepository
+	dlq       *DeadLetterQueue
+	sub       *nats.Subscription
+}
+
+func NewDeleteUserHandler(tracer trace.Tracer, connection *nats.Conn, repo repository.SocialGraphRepository, dlq *DeadLetterQueue) (*DeleteUserHandler, error) {
+	js, err := connection.JetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	processed, err := processedBucket(js)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &DeleteUserHandler{
+		tracer:    tracer,
+		conn:      connection,
+		processed: processed,
+		repo:      repo,
+		dlq:       dlq,
+	}
+
+	h.sub, err = subscribeDurable(js, DELETE_STREAM, DELETE_COMMAND, DELETE_CONSUMER, h.handleCommand)
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// Drain Stops taking commands and waits for the commands being handled. The durable consumer
+// is kept, the commands published in the meantime are delivered after the next start.
+func (h *DeleteUserHandler) Drain(ctx context.Context) error {
+	return messaging.DrainSubscriptions(ctx, h.sub)
+}
+
+func (h DeleteUserHandler) handleCommand(msg *nats.Msg) {
+	// without trace headers the command is still handled, in a new trace
+	remoteCtx, traceErr := tracing.GetNATSParentContext(msg)
+
+	ctx, span := otel.Tracer("social-graph").Start(trace.ContextWithRemoteSpanContext(context.Background(), remoteCtx), "DeleteUserHandler.handleCommand")
+	defer span.End()
+
+	if traceErr != nil {
+		log.Printf("%s without trace context: %v", msg.Subject, traceErr)
+		span.AddEvent("missing trace context")
+	}
+
+	var c DeleteUserCommand
+
+	err := json.Unmarshal(msg.Data, &c)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		h.dlq.Publish(ctx, msg, err)
+		return
+	}
+
+	key := processedKey(deleteSaga, c.Username, int8(c.Command))
+	if isProcessed(h.processed, key) {
+		msg.Ack()
+		return
+	}
+
+	done := false
+	switch c.Command {
+	case DeleteSocialGraph:
+		done, err = h.handleDeleteSocialGraph(ctx, c.Username, isLastAttempt(msg))
+	default:
+		err = fmt.Errorf("unknown delete command %d", c.Command)
+		span.SetStatus(codes.Error, err.Error())
+		h.dlq.Publish(ctx, msg, err)
+		return
+	}
+
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		msg.NakWithDelay(retryDelay)
+		return
+	}
+
+	// a failure reply leaves the command unprocessed, so that the saga can issue it again
+	if done {
+		markProcessed(h.processed, key)
+	}
+	msg.Ack()
+}
+
+// handleDeleteSocialGraph Deletes the user with its follows and follow requests, its tweets are
+// removed from the feeds of its followers through the outbox. Returns whether the user was
+// deleted and an error when the command should be redelivered, on the last attempt a failure
+// is replied instead.
+func (h DeleteUserHandler) handleDeleteSocialGraph(ctx context.Context, username string, lastAttempt bool) (bool, error) {
+	handlerCtx, span := h.tracer.Start(ctx, "DeleteUserHandler.handleDeleteSocialGraph")
+	defer span.End()
+
+	err := h.repo.DeleteUser(handlerCtx, username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+
+		if !lastAttempt {
+			return false, err
+		}
+
+		return false, h.sendReply(handlerCtx, DeleteUserReply{
+			Reply:    SocialGraphDeleteFail,
+			Username: username,
+		})
+	}
+
+	// the username can be registered again
+	forget(h.processed, processedKey(registerSaga, username, int8(SaveSocialGraph)))
+	forget(h.processed, processedKey(registerSaga, username, int8(RollbackSocialGraph)))
+
+	return true, h.sendReply(handlerCtx, DeleteUserReply{
+		Reply:    SocialGraphDeleteSuccess,
+		Username: username,
+	})
+}
+
+func (h DeleteUserHandler) sendReply(ctx context.Context, r DeleteUserReply) error {
+	_, span := h.tracer.Start(ctx, "DeleteUserHandler.sendReply")
+	defer span.End()
+
+	headers := nats.Header{}
+	headers.Set(tracing.TRACE_ID, span.SpanContext().TraceID().String())
+	headers.Set(tracing.SPAN_ID, span.SpanContext().SpanID().String())
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	msg := nats.Msg{
+		Subject: DELETE_REPLY,
+		Header:  headers,
+		Data:    data,
+	}
+
+	err = h.conn.PublishMsg(&msg)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
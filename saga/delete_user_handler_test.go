@@ -0,0 +1,112 @@
+package saga
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+	"time"
+)
+
+func TestDeleteUserHandler(t *testing.T) {
+	s := runNATSServer(t)
+	tracer := trace.NewNoopTracerProvider().Tracer("test")
+	repo := newCountingRepository()
+
+	conn := connect(t, s)
+	if _, err := NewDeleteUserHandler(tracer, conn, repo, newDeadLetterQueue(t, conn)); err != nil {
+		t.Fatal(err)
+	}
+
+	replyConn := connect(t, s)
+	replies, err := replyConn.SubscribeSync(DELETE_REPLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyConn.Flush()
+
+	data, _ := json.Marshal(DeleteUserCommand{Command: DeleteSocialGraph, Username: "carol"})
+	if err := connect(t, s).Publish(DELETE_COMMAND, data); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := replies.NextMsg(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r DeleteUserReply
+	if err := json.Unmarshal(msg.Data, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Reply != SocialGraphDeleteSuccess || r.Username != "carol" {
+		t.Errorf("got reply %+v", r)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.deleted["carol"] != 1 {
+		t.Errorf("deleted %d times, want 1", repo.deleted["carol"])
+	}
+}
+
+func TestDeleteUserHandlerReissuedAfterFailure(t *testing.T) {
+	s := runNATSServer(t)
+	tracer := trace.NewNoopTracerProvider().Tracer("test")
+	repo := newCountingRepository()
+	repo.deleteErr = errors.New("database is down")
+
+	conn := connect(t, s)
+	h, err := NewDeleteUserHandler(tracer, conn, repo, newDeadLetterQueue(t, conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replyConn := connect(t, s)
+	replies, err := replyConn.SubscribeSync(DELETE_REPLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyConn.Flush()
+
+	nextReply := func() DeleteUserReply {
+		msg, err := replies.NextMsg(5 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r DeleteUserReply
+		if err := json.Unmarshal(msg.Data, &r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	data, _ := json.Marshal(DeleteUserCommand{Command: DeleteSocialGraph, Username: "dave"})
+	// the last delivery of a JetStream message, as told by its reply subject
+	h.handleCommand(&nats.Msg{
+		Subject: DELETE_COMMAND,
+		Reply:   fmt.Sprintf("$JS.ACK.%s.%s.%d.1.1.%d.0", DELETE_STREAM, DELETE_CONSUMER, maxDeliver, time.Now().UnixNano()),
+		Data:    data,
+	})
+	if r := nextReply(); r.Reply != SocialGraphDeleteFail {
+		t.Fatalf("got reply %+v, want a failure", r)
+	}
+
+	// the saga issues the command again once the database is back
+	repo.mu.Lock()
+	repo.deleteErr = nil
+	repo.mu.Unlock()
+	if err := connect(t, s).Publish(DELETE_COMMAND, data); err != nil {
+		t.Fatal(err)
+	}
+	if r := nextReply(); r.Reply != SocialGraphDeleteSuccess {
+		t.Fatalf("got reply %+v, want a success", r)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.deleted["dave"] != 1 {
+		t.Errorf("deleted %d times, want 1", repo.deleted["dave"])
+	}
+}
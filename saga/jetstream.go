@@ -0,0 +1,132 @@
+package saga
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats.go"
+	"log"
+	"time"
+)
+
+const (
+	// PROCESSED_BUCKET Key-value bucket of the commands already handled, used to drop redeliveries.
+	PROCESSED_BUCKET = "social-graph-saga"
+
+	maxDeliver = 5
+	ackWait    = 30 * time.Second
+	retryDelay = 5 * time.Second
+	retention  = 7 * 24 * time.Hour
+)
+
+// subscribeDurable Subscribes handler to subject through a durable consumer shared by all
+// replicas as a queue group, so each command is handled by one of them. The stream keeps
+// commands published while the service is down and is created if missing.
+func subscribeDurable(js nats.JetStreamContext, stream string, subject string, consumer string, handler nats.MsgHandler) (*nats.Subscription, error) {
+	_, err := js.StreamInfo(stream)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(&nats.StreamConfig{
+			Name:     stream,
+			Subjects: []string{subject},
+			MaxAge:   retention,
+		})
+		if err != nil {
+			// another service may have captured the subject in its own stream, which the subscription finds by itself
+			log.Printf("failed to create stream %s: %v", stream, err)
+		}
+	}
+
+	// created up front, so that the subscription doesn't own the consumer and draining it on
+	// shutdown doesn't delete the consumer shared with the other replicas
+	err = addConsumer(js, subject, consumer)
+	if err != nil {
+		return nil, err
+	}
+
+	return js.QueueSubscribe(subject, consumer, handler,
+		nats.Durable(consumer),
+		nats.DeliverNew(),
+		nats.ManualAck(),
+		nats.AckExplicit(),
+		nats.AckWait(ackWait),
+		nats.MaxDeliver(maxDeliver),
+	)
+}
+
+// addConsumer Creates the durable push consumer of subject delivering to the consumer queue
+// group, unless it exists.
+func addConsumer(js nats.JetStreamContext, subject string, consumer string) error {
+	stream, err := js.StreamNameBySubject(subject)
+	if err != nil {
+		return err
+	}
+
+	_, err = js.ConsumerInfo(stream, consumer)
+	if !errors.Is(err, nats.ErrConsumerNotFound) {
+		return err
+	}
+	_, err = js.AddConsumer(stream, &nats.ConsumerConfig{
+		Durable:        consumer,
+		DeliverSubject: nats.NewInbox(),
+		DeliverGroup:   consumer,
+		FilterSubject:  subject,
+		DeliverPolicy:  nats.DeliverNewPolicy,
+		AckPolicy:      nats.AckExplicitPolicy,
+		AckWait:        ackWait,
+		MaxDeliver:     maxDeliver,
+		ReplayPolicy:   nats.ReplayInstantPolicy,
+	})
+	if err != nil {
+		// another replica may have created it in the meantime
+		if _, infoErr := js.ConsumerInfo(stream, consumer); infoErr == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func processedBucket(js nats.JetStreamContext) (nats.KeyValue, error) {
+	processed, err := js.KeyValue(PROCESSED_BUCKET)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		processed, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket: PROCESSED_BUCKET,
+			TTL:    retention,
+		})
+	}
+	return processed, err
+}
+
+// processedKey Key of a command in PROCESSED_BUCKET. Usernames are hex encoded since
+// keys allow only a few characters.
+func processedKey(saga string, username string, command int8) string {
+	return fmt.Sprintf("%s.%s.%d", saga, hex.EncodeToString([]byte(username)), command)
+}
+
+// isProcessed Whether the command was already handled and replied to.
+func isProcessed(processed nats.KeyValue, key string) bool {
+	_, err := processed.Get(key)
+	return err == nil
+}
+
+func markProcessed(processed nats.KeyValue, key string) {
+	if _, err := processed.Put(key, nil); err != nil {
+		log.Println(err)
+	}
+}
+
+// forget Removes key from the processed commands, so that the command is handled again.
+func forget(processed nats.KeyValue, key string) {
+	err := processed.Delete(key)
+	if err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		log.Println(err)
+	}
+}
+
+// isLastAttempt Whether msg won't be redelivered if it is not acknowledged.
+func isLastAttempt(msg *nats.Msg) bool {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return true
+	}
+	return meta.NumDelivered >= maxDeliver
+}
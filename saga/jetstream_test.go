@@ -0,0 +1,33 @@
+package saga
+
+import (
+	"github.com/nats-io/nats.go"
+	"testing"
+	"time"
+)
+
+func TestDrainingSubscriptionKeepsConsumer(t *testing.T) {
+	s := runNATSServer(t)
+	js, err := connect(t, s).JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := subscribeDurable(js, REGISTER_STREAM, REGISTER_COMMAND, REGISTER_CONSUMER, func(*nats.Msg) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); sub.IsValid(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("subscription not drained")
+		}
+	}
+
+	// the consumer is shared with the other replicas and keeps the commands for the next start
+	if _, err := js.ConsumerInfo(REGISTER_STREAM, REGISTER_CONSUMER); err != nil {
+		t.Errorf("consumer deleted by draining: %v", err)
+	}
+}
@@ -8,35 +8,57 @@ import (
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/context"
-	"os"
+	"log"
+	"social-graph/messaging"
 	"social-graph/repository"
 	"social-graph/tracing"
+	"time"
+)
+
+const (
+	// REGISTER_STREAM JetStream stream keeping commands published while the service is down.
+	REGISTER_STREAM   = "REGISTER_COMMANDS"
+	REGISTER_CONSUMER = "social-graph-register"
+	// registerSaga Prefix of the processed keys of register commands.
+	registerSaga = "register"
+	// registrationTimeout How long after SaveSocialGraph a registration can still be rolled back.
+	// The registration is complete on this side once ConfirmAuth arrives or this passes, whichever
+	// is first, so a lost ConfirmAuth can't leave the user deletable by a later rollback.
+	registrationTimeout = time.Hour
 )
 
 type RegisterUserHandler struct {
-	tracer trace.Tracer
-	conn   *nats.Conn
-	repo   repository.SocialGraphRepository
+	tracer    trace.Tracer
+	conn      *nats.Conn
+	processed nats.KeyValue
+	repo      repository.SocialGraphRepository
+	dlq       *DeadLetterQueue
+	sub       *nats.Subscription
 }
 
-func NewRegisterUserHandler(tracer trace.Tracer, repo repository.SocialGraphRepository) (*RegisterUserHandler, error) {
-	natsHost := os.Getenv("NATS_HOST")
-	natsPort := os.Getenv("NATS_PORT")
-
-	url := fmt.Sprintf("nats://%s:%s", natsHost, natsPort)
+// NewRegisterUserHandler Consumes register commands through a durable JetStream consumer
+// shared by all replicas. A command is acknowledged only after its reply is sent, failed
+// commands are redelivered up to maxDeliver times, malformed ones are moved to dlq.
+func NewRegisterUserHandler(tracer trace.Tracer, connection *nats.Conn, repo repository.SocialGraphRepository, dlq *DeadLetterQueue) (*RegisterUserHandler, error) {
+	js, err := connection.JetStream()
+	if err != nil {
+		return nil, err
+	}
 
-	connection, err := nats.Connect(url)
+	processed, err := processedBucket(js)
 	if err != nil {
 		return nil, err
 	}
 
 	h := &RegisterUserHandler{
-		tracer: tracer,
-		conn:   connection,
-		repo:   repo,
+		tracer:    tracer,
+		conn:      connection,
+		processed: processed,
+		repo:      repo,
+		dlq:       dlq,
 	}
 
-	_, err = connection.Subscribe(REGISTER_COMMAND, h.handleCommand)
+	h.sub, err = subscribeDurable(js, REGISTER_STREAM, REGISTER_COMMAND, REGISTER_CONSUMER, h.handleCommand)
 	if err != nil {
 		return nil, err
 	}
@@ -44,30 +66,83 @@ func NewRegisterUserHandler(tracer trace.Tracer, repo repository.SocialGraphRepo
 	return h, nil
 }
 
-func (h RegisterUserHandler) handleCommand(msg *nats.Msg) {
-	remoteCtx, err := tracing.GetNATSParentContext(msg)
-	if err != nil {
+// Drain Stops taking commands and waits for the commands being handled. The durable consumer
+// is kept, the commands published in the meantime are delivered after the next start.
+func (h *RegisterUserHandler) Drain(ctx context.Context) error {
+	return messaging.DrainSubscriptions(ctx, h.sub)
+}
 
-	}
+func (h RegisterUserHandler) handleCommand(msg *nats.Msg) {
+	// without trace headers the command is still handled, in a new trace
+	remoteCtx, traceErr := tracing.GetNATSParentContext(msg)
 
 	ctx, span := otel.Tracer("social-graph").Start(trace.ContextWithRemoteSpanContext(context.Background(), remoteCtx), "RegisterUserHandler.handleCommand")
 	defer span.End()
 
+	if traceErr != nil {
+		log.Printf("%s without trace context: %v", msg.Subject, traceErr)
+		span.AddEvent("missing trace context")
+	}
+
 	var c RegisterUserCommand
 
-	err = json.Unmarshal(msg.Data, &c)
+	err := json.Unmarshal(msg.Data, &c)
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
+		// redelivering won't fix it
+		h.dlq.Publish(ctx, msg, err)
 		return
 	}
 
+	key := processedKey(registerSaga, c.User.Username, int8(c.Command))
+	if isProcessed(h.processed, key) {
+		// already handled and replied to, this is a redelivery
+		msg.Ack()
+		return
+	}
+
+	lastAttempt := isLastAttempt(msg)
+
+	done := true
 	switch c.Command {
 	case SaveSocialGraph:
-		h.handleSaveSocialGraph(ctx, c.User)
+		done, err = h.handleSaveSocialGraph(ctx, c.User, lastAttempt)
+	case RollbackSocialGraph:
+		err = h.handleRollbackSocialGraph(ctx, c.User)
+	case ConfirmAuth:
+		err = h.handleConfirmAuth(ctx, c.User)
+	case SaveProfile, RollbackProfile, RollbackAuth:
+		// commands of other saga participants
+		msg.Ack()
+		return
+	default:
+		err = fmt.Errorf("unknown register command %d", c.Command)
+		span.SetStatus(codes.Error, err.Error())
+		h.dlq.Publish(ctx, msg, err)
+		return
+	}
+
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		if lastAttempt {
+			// it won't be redelivered, an admin has to replay it
+			h.dlq.Publish(ctx, msg, err)
+			return
+		}
+		msg.NakWithDelay(retryDelay)
+		return
 	}
+
+	// a failure reply leaves the command unprocessed, so that the saga can issue it again
+	if done {
+		markProcessed(h.processed, key)
+	}
+	msg.Ack()
 }
 
-func (h RegisterUserHandler) handleSaveSocialGraph(ctx context.Context, user NewUser) {
+// handleSaveSocialGraph Returns whether the user was saved and an error when the command should
+// be redelivered. On the last attempt a failure is replied instead.
+func (h RegisterUserHandler) handleSaveSocialGraph(ctx context.Context, user NewUser, lastAttempt bool) (bool, error) {
 	handlerCtx, span := h.tracer.Start(ctx, "RegisterUserHandler.handleSaveSocialGraph")
 	defer span.End()
 
@@ -80,26 +155,71 @@ func (h RegisterUserHandler) handleSaveSocialGraph(ctx context.Context, user New
 		private = false
 	}
 
-	err := h.repo.CreateNewUser(handlerCtx, user.Username, private)
+	err := h.repo.RegisterUser(handlerCtx, user.Username, private)
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
 
-		h.sendReply(handlerCtx, RegisterUserReply{
+		if !lastAttempt {
+			return false, err
+		}
+
+		return false, h.sendReply(handlerCtx, RegisterUserReply{
 			Reply: SocialGraphFail,
 			User:  user,
 		})
-
-		return
 	}
 
-	h.sendReply(handlerCtx, RegisterUserReply{
+	// a registration with the same username may have been rolled back or deleted before
+	forget(h.processed, processedKey(registerSaga, user.Username, int8(RollbackSocialGraph)))
+	forget(h.processed, processedKey(registerSaga, user.Username, int8(ConfirmAuth)))
+	forget(h.processed, processedKey(deleteSaga, user.Username, int8(DeleteSocialGraph)))
+
+	return true, h.sendReply(handlerCtx, RegisterUserReply{
 		Reply: SocialGraphSuccess,
 		User:  user,
 	})
 
 }
 
-func (h RegisterUserHandler) sendReply(ctx context.Context, r RegisterUserReply) {
+// handleRollbackSocialGraph Deletes the user saved by SaveSocialGraph, if this registration
+// created it at all and isn't complete yet. A user that existed before, was confirmed or was
+// registered more than registrationTimeout ago is kept. Returns an error when the command
+// should be redelivered, there is no failure reply for rollbacks, so on the last attempt the
+// command is dead lettered.
+func (h RegisterUserHandler) handleRollbackSocialGraph(ctx context.Context, user NewUser) error {
+	handlerCtx, span := h.tracer.Start(ctx, "RegisterUserHandler.handleRollbackSocialGraph")
+	defer span.End()
+
+	_, err := h.repo.RollbackRegistration(handlerCtx, user.Username, time.Now().Add(-registrationTimeout))
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	// the username can be registered again
+	forget(h.processed, processedKey(registerSaga, user.Username, int8(SaveSocialGraph)))
+
+	return h.sendReply(handlerCtx, RegisterUserReply{
+		Reply: SocialGraphRollback,
+		User:  user,
+	})
+}
+
+// handleConfirmAuth Completes the registration, after which the user can't be rolled back.
+// Returns an error when the command should be redelivered.
+func (h RegisterUserHandler) handleConfirmAuth(ctx context.Context, user NewUser) error {
+	handlerCtx, span := h.tracer.Start(ctx, "RegisterUserHandler.handleConfirmAuth")
+	defer span.End()
+
+	err := h.repo.ConfirmRegistration(handlerCtx, user.Username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (h RegisterUserHandler) sendReply(ctx context.Context, r RegisterUserReply) error {
 	_, span := h.tracer.Start(ctx, "RegisterUserHandler.sendReply")
 	defer span.End()
 
@@ -110,7 +230,7 @@ func (h RegisterUserHandler) sendReply(ctx context.Context, r RegisterUserReply)
 	data, err := json.Marshal(r)
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
-		return
+		return err
 	}
 
 	msg := nats.Msg{
@@ -122,5 +242,7 @@ func (h RegisterUserHandler) sendReply(ctx context.Context, r RegisterUserReply)
 	err = h.conn.PublishMsg(&msg)
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
+		return err
 	}
+	return nil
 }
@@ -0,0 +1,472 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/trace"
+	"social-graph/repository"
+	"sync"
+	"testing"
+	"time"
+)
+
+type countingRepository struct {
+	repository.SocialGraphRepository
+	mu      sync.Mutex
+	created map[string]int
+	deleted map[string]int
+	// registering Users created by RegisterUser and not confirmed yet, with the time they were created.
+	registering map[string]time.Time
+	// existing Users that exist without a registration.
+	existing map[string]bool
+	// rollbackErr Returned by RollbackRegistration.
+	rollbackErr error
+	// deleteErr Returned by DeleteUser.
+	deleteErr error
+	// registerErr Returned by RegisterUser.
+	registerErr error
+}
+
+func newCountingRepository() *countingRepository {
+	return &countingRepository{
+		created:     map[string]int{},
+		deleted:     map[string]int{},
+		registering: map[string]time.Time{},
+		existing:    map[string]bool{},
+	}
+}
+
+func (r *countingRepository) RegisterUser(_ context.Context, username string, _ bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.registerErr != nil {
+		return r.registerErr
+	}
+	if _, ok := r.registering[username]; ok || r.existing[username] {
+		return nil
+	}
+	r.created[username]++
+	r.registering[username] = time.Now()
+	return nil
+}
+
+func (r *countingRepository) ConfirmRegistration(_ context.Context, username string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.registering[username]; ok {
+		delete(r.registering, username)
+		r.existing[username] = true
+	}
+	return nil
+}
+
+func (r *countingRepository) RollbackRegistration(_ context.Context, username string, since time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rollbackErr != nil {
+		return false, r.rollbackErr
+	}
+	if registered, ok := r.registering[username]; !ok || registered.Before(since) {
+		return false, nil
+	}
+	delete(r.registering, username)
+	r.deleted[username]++
+	return true, nil
+}
+
+func (r *countingRepository) DeleteUser(_ context.Context, username string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.deleteErr != nil {
+		return r.deleteErr
+	}
+	r.deleted[username]++
+	return nil
+}
+
+func runNATSServer(t *testing.T) *server.Server {
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	t.Cleanup(s.Shutdown)
+	return s
+}
+
+func connect(t *testing.T, s *server.Server) *nats.Conn {
+	conn, err := nats.Connect(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+func newDeadLetterQueue(t *testing.T, conn *nats.Conn) *DeadLetterQueue {
+	dlq, err := NewDeadLetterQueue(trace.NewNoopTracerProvider().Tracer("test"), conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dlq
+}
+
+func TestRegisterUserHandlerReplicasProcessEachCommandOnce(t *testing.T) {
+	s := runNATSServer(t)
+	tracer := trace.NewNoopTracerProvider().Tracer("test")
+	repo := newCountingRepository()
+
+	for i := 0; i < 3; i++ {
+		conn := connect(t, s)
+		if _, err := NewRegisterUserHandler(tracer, conn, repo, newDeadLetterQueue(t, conn)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	replies := map[string]int{}
+	replyConn := connect(t, s)
+	_, err := replyConn.Subscribe(REGISTER_REPLY, func(msg *nats.Msg) {
+		var r RegisterUserReply
+		if err := json.Unmarshal(msg.Data, &r); err != nil {
+			t.Error(err)
+			return
+		}
+		mu.Lock()
+		replies[r.User.Username]++
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyConn.Flush()
+
+	const users = 50
+	publisher := connect(t, s)
+	for i := 0; i < users; i++ {
+		data, _ := json.Marshal(RegisterUserCommand{
+			Command: SaveSocialGraph,
+			User:    NewUser{Username: fmt.Sprintf("user%d", i), Role: "ROLE_USER"},
+		})
+		if err := publisher.Publish(REGISTER_COMMAND, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		mu.Lock()
+		n := len(replies)
+		mu.Unlock()
+		if n == users {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got replies for %d of %d users", n, users)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	// give duplicates a chance to show up
+	time.Sleep(500 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for i := 0; i < users; i++ {
+		username := fmt.Sprintf("user%d", i)
+		if replies[username] != 1 {
+			t.Errorf("%s: got %d replies, want 1", username, replies[username])
+		}
+		if repo.created[username] != 1 {
+			t.Errorf("%s: created %d times, want 1", username, repo.created[username])
+		}
+	}
+}
+
+func TestRegisterUserHandlerIgnoresRedeliveredCommand(t *testing.T) {
+	s := runNATSServer(t)
+	tracer := trace.NewNoopTracerProvider().Tracer("test")
+	repo := newCountingRepository()
+
+	conn := connect(t, s)
+	if _, err := NewRegisterUserHandler(tracer, conn, repo, newDeadLetterQueue(t, conn)); err != nil {
+		t.Fatal(err)
+	}
+
+	replyConn := connect(t, s)
+	replies, err := replyConn.SubscribeSync(REGISTER_REPLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyConn.Flush()
+
+	data, _ := json.Marshal(RegisterUserCommand{
+		Command: SaveSocialGraph,
+		User:    NewUser{Username: "alice", Role: "ROLE_USER"},
+	})
+	publisher := connect(t, s)
+	for i := 0; i < 2; i++ {
+		if err := publisher.Publish(REGISTER_COMMAND, data); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := replies.NextMsg(5 * time.Second); i == 0 && err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := replies.NextMsg(500 * time.Millisecond); err != nats.ErrTimeout {
+		t.Errorf("got a second reply, err = %v", err)
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.created["alice"] != 1 {
+		t.Errorf("created %d times, want 1", repo.created["alice"])
+	}
+}
+
+func TestRegisterUserHandlerRollback(t *testing.T) {
+	s := runNATSServer(t)
+	tracer := trace.NewNoopTracerProvider().Tracer("test")
+	repo := newCountingRepository()
+
+	conn := connect(t, s)
+	if _, err := NewRegisterUserHandler(tracer, conn, repo, newDeadLetterQueue(t, conn)); err != nil {
+		t.Fatal(err)
+	}
+
+	replyConn := connect(t, s)
+	replies, err := replyConn.SubscribeSync(REGISTER_REPLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyConn.Flush()
+
+	publisher := connect(t, s)
+	user := NewUser{Username: "bob", Role: "ROLE_USER"}
+	// register, roll back, then register the same username again
+	for _, step := range []struct {
+		command RegisterUserCommandType
+		reply   RegisterUserReplyType
+	}{
+		{SaveSocialGraph, SocialGraphSuccess},
+		{RollbackSocialGraph, SocialGraphRollback},
+		{SaveSocialGraph, SocialGraphSuccess},
+	} {
+		data, _ := json.Marshal(RegisterUserCommand{Command: step.command, User: user})
+		if err := publisher.Publish(REGISTER_COMMAND, data); err != nil {
+			t.Fatal(err)
+		}
+		msg, err := replies.NextMsg(5 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r RegisterUserReply
+		if err := json.Unmarshal(msg.Data, &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Reply != step.reply {
+			t.Fatalf("got reply %d, want %d", r.Reply, step.reply)
+		}
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.created["bob"] != 2 || repo.deleted["bob"] != 1 {
+		t.Errorf("created %d and deleted %d times, want 2 and 1", repo.created["bob"], repo.deleted["bob"])
+	}
+}
+
+func TestRegisterUserHandlerRollbackKeepsExistingUser(t *testing.T) {
+	s := runNATSServer(t)
+	tracer := trace.NewNoopTracerProvider().Tracer("test")
+	repo := newCountingRepository()
+	repo.existing["carol"] = true
+
+	conn := connect(t, s)
+	if _, err := NewRegisterUserHandler(tracer, conn, repo, newDeadLetterQueue(t, conn)); err != nil {
+		t.Fatal(err)
+	}
+
+	replyConn := connect(t, s)
+	replies, err := replyConn.SubscribeSync(REGISTER_REPLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyConn.Flush()
+
+	publisher := connect(t, s)
+	// dave is registered and confirmed, carol existed before her registration
+	for _, c := range []RegisterUserCommand{
+		{Command: SaveSocialGraph, User: NewUser{Username: "dave"}},
+		{Command: ConfirmAuth, User: NewUser{Username: "dave"}},
+		{Command: RollbackSocialGraph, User: NewUser{Username: "dave"}},
+		{Command: SaveSocialGraph, User: NewUser{Username: "carol"}},
+		{Command: RollbackSocialGraph, User: NewUser{Username: "carol"}},
+	} {
+		data, _ := json.Marshal(c)
+		if err := publisher.Publish(REGISTER_COMMAND, data); err != nil {
+			t.Fatal(err)
+		}
+		if c.Command == ConfirmAuth {
+			continue
+		}
+		if _, err := replies.NextMsg(5 * time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if !repo.existing["carol"] || !repo.existing["dave"] || len(repo.deleted) != 0 {
+		t.Errorf("rolled back users %v, existing %v", repo.deleted, repo.existing)
+	}
+}
+
+func TestRegisterUserHandlerRollbackKeepsCompletedRegistration(t *testing.T) {
+	s := runNATSServer(t)
+	tracer := trace.NewNoopTracerProvider().Tracer("test")
+	repo := newCountingRepository()
+	// frank registered long ago and his ConfirmAuth was lost, grace registered just now
+	repo.registering["frank"] = time.Now().Add(-2 * registrationTimeout)
+	repo.registering["grace"] = time.Now()
+
+	conn := connect(t, s)
+	if _, err := NewRegisterUserHandler(tracer, conn, repo, newDeadLetterQueue(t, conn)); err != nil {
+		t.Fatal(err)
+	}
+
+	replyConn := connect(t, s)
+	replies, err := replyConn.SubscribeSync(REGISTER_REPLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyConn.Flush()
+
+	publisher := connect(t, s)
+	for _, username := range []string{"frank", "grace"} {
+		data, _ := json.Marshal(RegisterUserCommand{Command: RollbackSocialGraph, User: NewUser{Username: username}})
+		if err := publisher.Publish(REGISTER_COMMAND, data); err != nil {
+			t.Fatal(err)
+		}
+		msg, err := replies.NextMsg(5 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r RegisterUserReply
+		if err := json.Unmarshal(msg.Data, &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Reply != SocialGraphRollback {
+			t.Fatalf("got reply %d for %s, want %d", r.Reply, username, SocialGraphRollback)
+		}
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.deleted["frank"] != 0 || repo.deleted["grace"] != 1 {
+		t.Errorf("rolled back %v, want only grace", repo.deleted)
+	}
+}
+
+func TestRegisterUserHandlerDeadLettersFailedRollback(t *testing.T) {
+	s := runNATSServer(t)
+	tracer := trace.NewNoopTracerProvider().Tracer("test")
+	repo := newCountingRepository()
+	repo.rollbackErr = errors.New("database is down")
+
+	conn := connect(t, s)
+	dlq := newDeadLetterQueue(t, conn)
+	h, err := NewRegisterUserHandler(tracer, conn, repo, dlq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := json.Marshal(RegisterUserCommand{Command: RollbackSocialGraph, User: NewUser{Username: "erin"}})
+	// the last delivery of a JetStream message, as told by its reply subject
+	h.handleCommand(&nats.Msg{
+		Subject: REGISTER_COMMAND,
+		Reply:   fmt.Sprintf("$JS.ACK.%s.%s.%d.1.1.%d.0", REGISTER_STREAM, REGISTER_CONSUMER, maxDeliver, time.Now().UnixNano()),
+		Data:    data,
+	})
+
+	letters, err := dlq.List(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].Payload != string(data) || letters[0].Error != repo.rollbackErr.Error() {
+		t.Errorf("got dead letters %+v", letters)
+	}
+}
+
+func TestRegisterUserHandlerReissuedAfterFailure(t *testing.T) {
+	s := runNATSServer(t)
+	tracer := trace.NewNoopTracerProvider().Tracer("test")
+	repo := newCountingRepository()
+	repo.registerErr = errors.New("database is down")
+
+	conn := connect(t, s)
+	h, err := NewRegisterUserHandler(tracer, conn, repo, newDeadLetterQueue(t, conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replyConn := connect(t, s)
+	replies, err := replyConn.SubscribeSync(REGISTER_REPLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replyConn.Flush()
+
+	nextReply := func() RegisterUserReply {
+		msg, err := replies.NextMsg(5 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r RegisterUserReply
+		if err := json.Unmarshal(msg.Data, &r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	data, _ := json.Marshal(RegisterUserCommand{Command: SaveSocialGraph, User: NewUser{Username: "heidi"}})
+	// the last delivery of a JetStream message, as told by its reply subject
+	h.handleCommand(&nats.Msg{
+		Subject: REGISTER_COMMAND,
+		Reply:   fmt.Sprintf("$JS.ACK.%s.%s.%d.1.1.%d.0", REGISTER_STREAM, REGISTER_CONSUMER, maxDeliver, time.Now().UnixNano()),
+		Data:    data,
+	})
+	if r := nextReply(); r.Reply != SocialGraphFail {
+		t.Fatalf("got reply %+v, want a failure", r)
+	}
+
+	// the saga issues the command again once the database is back
+	repo.mu.Lock()
+	repo.registerErr = nil
+	repo.mu.Unlock()
+	if err := connect(t, s).Publish(REGISTER_COMMAND, data); err != nil {
+		t.Fatal(err)
+	}
+	if r := nextReply(); r.Reply != SocialGraphSuccess {
+		t.Fatalf("got reply %+v, want a success", r)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if repo.created["heidi"] != 1 {
+		t.Errorf("created %d times, want 1", repo.created["heidi"])
+	}
+}
@@ -4,22 +4,24 @@ import (
 	"context"
 	"github.com/FTN-TwitterClone/grpc-stubs/proto/social_graph"
 	"github.com/golang/protobuf/ptypes/empty"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
-	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"social-graph/repository"
 )
 
 type gRPCSocialGraphService struct {
 	social_graph.UnimplementedSocialGraphServiceServer
-	tracer trace.Tracer
-	repo   repository.SocialGraphRepository
+	tracer             trace.Tracer
+	repo               repository.SocialGraphRepository
+	socialGraphService *SocialGraphService
 }
 
-func NewgRPCSocialGraphService(tracer trace.Tracer, repo repository.SocialGraphRepository) *gRPCSocialGraphService {
+func NewgRPCSocialGraphService(tracer trace.Tracer, repo repository.SocialGraphRepository, socialGraphService *SocialGraphService) *gRPCSocialGraphService {
 	return &gRPCSocialGraphService{
-		tracer: tracer,
-		repo:   repo,
+		tracer:             tracer,
+		repo:               repo,
+		socialGraphService: socialGraphService,
 	}
 }
 
@@ -27,10 +29,16 @@ func (s gRPCSocialGraphService) CheckVisibility(ctx context.Context, gRPCUsernam
 	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphService.CheckVisibility")
 	defer span.End()
 
-	md, _ := metadata.FromIncomingContext(ctx)
-	authUsername := md.Get("authUsername")[0]
+	authUsername, err := getAuthUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	visible, _ := s.repo.CanAccessTweetOfAnotherUser(serviceCtx, authUsername, gRPCUsername.Username)
+	visible, err := s.repo.CanAccessTweetOfAnotherUser(serviceCtx, authUsername, gRPCUsername.Username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
 
 	return &social_graph.SocialGraphVisibilityUserResponse{Visibility: visible}, nil
 }
@@ -39,10 +47,16 @@ func (s gRPCSocialGraphService) GetMyFollowers(ctx context.Context, empty *empty
 	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphService.GetMyFollowers")
 	defer span.End()
 
-	md, _ := metadata.FromIncomingContext(ctx)
-	authUsername := md.Get("authUsername")[0]
+	authUsername, err := getAuthUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	users, _ := s.repo.GetFollowers(serviceCtx, authUsername)
+	users, err := s.repo.GetFollowers(serviceCtx, authUsername)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
 	usersUsername := []*social_graph.SocialGraphUsername{}
 	for _, user := range users {
 		usersUsername = append(usersUsername, &social_graph.SocialGraphUsername{Username: user.Username})
@@ -54,16 +68,14 @@ func (s gRPCSocialGraphService) SocialGraphUpdateUser(ctx context.Context, user
 	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphService.SocialGraphUpdatedUser")
 	defer span.End()
 
-	md, ok := metadata.FromIncomingContext(ctx)
-
-	if !ok {
-		return new(empty.Empty), nil
+	authUsername, err := getAuthUsername(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	authUsername := md.Get("authUsername")[0]
-
-	err := s.repo.UpdateUser(serviceCtx, user.Private, authUsername)
+	_, err = s.socialGraphService.UpdateUser(serviceCtx, authUsername, user.Private)
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 	return new(empty.Empty), nil
@@ -0,0 +1,425 @@
+package service
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"social-graph/interceptor"
+	"social-graph/model"
+	socialgraphv1 "social-graph/proto/socialgraph/v1"
+	"time"
+)
+
+// PublicMethodsV1 Methods of the socialgraph.v1 API that can be called without authUsername.
+var PublicMethodsV1 = []string{
+	"/socialgraph.v1.SocialGraphService/GetFollowing",
+	"/socialgraph.v1.SocialGraphService/GetFollowingCount",
+	"/socialgraph.v1.SocialGraphService/GetFollowers",
+	"/socialgraph.v1.SocialGraphService/GetFollowersCount",
+	"/socialgraph.v1.SocialGraphService/StreamFollowers",
+	"/socialgraph.v1.SocialGraphService/CheckVisibility",
+}
+
+// gRPCSocialGraphServiceV1 Serves the socialgraph.v1 API, the gRPC equivalent of SocialGraphController.
+type gRPCSocialGraphServiceV1 struct {
+	socialgraphv1.UnimplementedSocialGraphServiceServer
+	tracer             trace.Tracer
+	socialGraphService *SocialGraphService
+}
+
+func NewgRPCSocialGraphServiceV1(tracer trace.Tracer, socialGraphService *SocialGraphService) *gRPCSocialGraphServiceV1 {
+	return &gRPCSocialGraphServiceV1{
+		tracer:             tracer,
+		socialGraphService: socialGraphService,
+	}
+}
+
+func (s gRPCSocialGraphServiceV1) CreateFollow(ctx context.Context, req *socialgraphv1.CreateFollowRequest) (*socialgraphv1.CreateFollowResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.CreateFollow")
+	defer span.End()
+
+	authUsername, err := getAuthUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.Username == "" {
+		return nil, status.Error(grpccodes.InvalidArgument, "username is required")
+	}
+	if req.Username == authUsername {
+		return nil, status.Error(grpccodes.InvalidArgument, "cant follow yourself")
+	}
+
+	err = s.socialGraphService.CreateFollow(serviceCtx, authUsername, req.Username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &socialgraphv1.CreateFollowResponse{}, nil
+}
+
+func (s gRPCSocialGraphServiceV1) RemoveFollow(ctx context.Context, req *socialgraphv1.RemoveFollowRequest) (*socialgraphv1.RemoveFollowResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.RemoveFollow")
+	defer span.End()
+
+	authUsername, err := getAuthUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.socialGraphService.RemoveFollow(serviceCtx, authUsername, req.Username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &socialgraphv1.RemoveFollowResponse{}, nil
+}
+
+func (s gRPCSocialGraphServiceV1) RemoveFollower(ctx context.Context, req *socialgraphv1.RemoveFollowerRequest) (*socialgraphv1.RemoveFollowerResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.RemoveFollower")
+	defer span.End()
+
+	authUsername, err := getAuthUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.socialGraphService.RemoveFollower(serviceCtx, authUsername, req.Username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &socialgraphv1.RemoveFollowerResponse{}, nil
+}
+
+func (s gRPCSocialGraphServiceV1) UpdatePrivacy(ctx context.Context, req *socialgraphv1.UpdatePrivacyRequest) (*socialgraphv1.UpdatePrivacyResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.UpdatePrivacy")
+	defer span.End()
+
+	authUsername, err := getAuthUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	change, err := s.socialGraphService.UpdateUser(serviceCtx, authUsername, req.Private)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &socialgraphv1.UpdatePrivacyResponse{
+		Private:   change.Private,
+		Approved:  toProtoUsers(change.Approved),
+		Followers: toProtoUsers(change.Followers),
+	}, nil
+}
+
+func (s gRPCSocialGraphServiceV1) GetFollowing(ctx context.Context, req *socialgraphv1.GetFollowingRequest) (*socialgraphv1.GetFollowingResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.GetFollowing")
+	defer span.End()
+
+	users, err := s.socialGraphService.GetFollowing(serviceCtx, req.Username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &socialgraphv1.GetFollowingResponse{Users: toProtoUsers(users)}, nil
+}
+
+func (s gRPCSocialGraphServiceV1) GetFollowingCount(ctx context.Context, req *socialgraphv1.GetFollowingCountRequest) (*socialgraphv1.GetFollowingCountResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.GetFollowingCount")
+	defer span.End()
+
+	counts, err := s.socialGraphService.GetFollowCounts(serviceCtx, req.Username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &socialgraphv1.GetFollowingCountResponse{Count: counts.Following}, nil
+}
+
+func (s gRPCSocialGraphServiceV1) GetFollowers(ctx context.Context, req *socialgraphv1.GetFollowersRequest) (*socialgraphv1.GetFollowersResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.GetFollowers")
+	defer span.End()
+
+	users, err := s.socialGraphService.GetFollowers(serviceCtx, req.Username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &socialgraphv1.GetFollowersResponse{Users: toProtoUsers(users)}, nil
+}
+
+// StreamFollowers Sends block while the client is behind, which in turn stops reading followers
+// from the database.
+func (s gRPCSocialGraphServiceV1) StreamFollowers(req *socialgraphv1.StreamFollowersRequest, stream socialgraphv1.SocialGraphService_StreamFollowersServer) error {
+	serviceCtx, span := s.tracer.Start(stream.Context(), "gRPCSocialGraphServiceV1.StreamFollowers")
+	defer span.End()
+
+	username := req.Username
+	if username == "" {
+		authUsername, err := getAuthUsername(stream.Context())
+		if err != nil {
+			return err
+		}
+		username = authUsername
+	}
+	chunkSize := int(req.ChunkSize)
+	if chunkSize == 0 {
+		chunkSize = DefaultFollowersChunkSize
+	}
+	if chunkSize < 1 || chunkSize > MaxFollowersChunkSize {
+		return status.Errorf(grpccodes.InvalidArgument, "chunk size must be between 1 and %d", MaxFollowersChunkSize)
+	}
+
+	err := s.socialGraphService.StreamFollowers(serviceCtx, username, req.Cursor, chunkSize, func(users []model.User) error {
+		return stream.Send(&socialgraphv1.StreamFollowersResponse{
+			Users:  toProtoUsers(users),
+			Cursor: users[len(users)-1].Username,
+		})
+	})
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+func (s gRPCSocialGraphServiceV1) GetFollowersCount(ctx context.Context, req *socialgraphv1.GetFollowersCountRequest) (*socialgraphv1.GetFollowersCountResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.GetFollowersCount")
+	defer span.End()
+
+	counts, err := s.socialGraphService.GetFollowCounts(serviceCtx, req.Username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &socialgraphv1.GetFollowersCountResponse{Count: counts.Followers}, nil
+}
+
+func (s gRPCSocialGraphServiceV1) CheckFollowExists(ctx context.Context, req *socialgraphv1.CheckFollowExistsRequest) (*socialgraphv1.CheckFollowExistsResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.CheckFollowExists")
+	defer span.End()
+
+	authUsername, err := getAuthUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := s.socialGraphService.CheckIfFollowExists(serviceCtx, authUsername, req.Username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &socialgraphv1.CheckFollowExistsResponse{Exists: exists}, nil
+}
+
+func (s gRPCSocialGraphServiceV1) AcceptRejectFollowRequest(ctx context.Context, req *socialgraphv1.AcceptRejectFollowRequestRequest) (*socialgraphv1.AcceptRejectFollowRequestResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.AcceptRejectFollowRequest")
+	defer span.End()
+
+	authUsername, err := getAuthUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.socialGraphService.AcceptRejectFollowRequest(serviceCtx, req.Username, authUsername, req.Approved)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &socialgraphv1.AcceptRejectFollowRequestResponse{}, nil
+}
+
+func (s gRPCSocialGraphServiceV1) CheckFollowRequestExists(ctx context.Context, req *socialgraphv1.CheckFollowRequestExistsRequest) (*socialgraphv1.CheckFollowRequestExistsResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.CheckFollowRequestExists")
+	defer span.End()
+
+	authUsername, err := getAuthUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := s.socialGraphService.CheckIfFollowRequestExists(serviceCtx, req.Username, authUsername)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &socialgraphv1.CheckFollowRequestExistsResponse{Exists: exists}, nil
+}
+
+func (s gRPCSocialGraphServiceV1) ListFollowRequests(ctx context.Context, _ *socialgraphv1.ListFollowRequestsRequest) (*socialgraphv1.ListFollowRequestsResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.ListFollowRequests")
+	defer span.End()
+
+	authUsername, err := getAuthUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.socialGraphService.GetAllFollowRequests(serviceCtx, authUsername)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &socialgraphv1.ListFollowRequestsResponse{Users: toProtoUsers(users)}, nil
+}
+
+func (s gRPCSocialGraphServiceV1) CheckVisibility(ctx context.Context, req *socialgraphv1.CheckVisibilityRequest) (*socialgraphv1.CheckVisibilityResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.CheckVisibility")
+	defer span.End()
+
+	viewer := req.Viewer
+	if viewer == "" {
+		authUsername, err := getAuthUsername(ctx)
+		if err != nil {
+			return nil, err
+		}
+		viewer = authUsername
+	}
+	if len(req.Usernames) > MaxVisibilityUsernames {
+		return nil, status.Errorf(grpccodes.InvalidArgument, "at most %d usernames", MaxVisibilityUsernames)
+	}
+
+	visible, err := s.socialGraphService.CheckVisibility(serviceCtx, viewer, req.Usernames)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &socialgraphv1.CheckVisibilityResponse{Visibility: visible}, nil
+}
+
+func (s gRPCSocialGraphServiceV1) GetRecommendations(ctx context.Context, _ *socialgraphv1.GetRecommendationsRequest) (*socialgraphv1.GetRecommendationsResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.GetRecommendations")
+	defer span.End()
+
+	authUsername, err := getAuthUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.socialGraphService.GetRecommendationsProfile(serviceCtx, authUsername)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	return &socialgraphv1.GetRecommendationsResponse{Users: toProtoUsers(users)}, nil
+}
+
+func (s gRPCSocialGraphServiceV1) GetFollowerGrowth(ctx context.Context, req *socialgraphv1.GetFollowerGrowthRequest) (*socialgraphv1.GetFollowerGrowthResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.GetFollowerGrowth")
+	defer span.End()
+
+	authUsername, err := getAuthUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var interval time.Duration
+	switch req.Interval {
+	case socialgraphv1.GrowthInterval_GROWTH_INTERVAL_UNSPECIFIED, socialgraphv1.GrowthInterval_GROWTH_INTERVAL_DAY:
+		interval = 24 * time.Hour
+	case socialgraphv1.GrowthInterval_GROWTH_INTERVAL_HOUR:
+		interval = time.Hour
+	default:
+		return nil, status.Error(grpccodes.InvalidArgument, "interval must be hour or day")
+	}
+
+	to := time.Now()
+	if req.To != nil {
+		to = req.To.AsTime()
+	}
+	from := to.Add(-30 * interval)
+	if req.From != nil {
+		from = req.From.AsTime()
+	}
+	if !from.Before(to) {
+		return nil, status.Error(grpccodes.InvalidArgument, "from must be before to")
+	}
+	if to.Sub(from)/interval > MaxFollowerGrowthBuckets {
+		return nil, status.Error(grpccodes.InvalidArgument, "time range too large for interval")
+	}
+
+	growth, err := s.socialGraphService.GetFollowerGrowth(serviceCtx, authUsername, from, to, interval)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	buckets := make([]*socialgraphv1.FollowerGrowth, 0, len(growth))
+	for _, g := range growth {
+		buckets = append(buckets, &socialgraphv1.FollowerGrowth{
+			Timestamp: timestamppb.New(g.Timestamp),
+			Gained:    g.Gained,
+			Lost:      g.Lost,
+			Followers: g.Followers,
+		})
+	}
+	return &socialgraphv1.GetFollowerGrowthResponse{Buckets: buckets}, nil
+}
+
+func (s gRPCSocialGraphServiceV1) GetEgoNetwork(ctx context.Context, req *socialgraphv1.GetEgoNetworkRequest) (*socialgraphv1.GetEgoNetworkResponse, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "gRPCSocialGraphServiceV1.GetEgoNetwork")
+	defer span.End()
+
+	authUsername, err := getAuthUsername(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	depth := int(req.Depth)
+	if depth == 0 {
+		depth = MaxEgoNetworkDepth
+	}
+	if depth < 1 || depth > MaxEgoNetworkDepth {
+		return nil, status.Error(grpccodes.InvalidArgument, "depth must be 1 or 2")
+	}
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = DefaultEgoNetworkLimit
+	}
+	if limit < 1 || limit > MaxEgoNetworkLimit {
+		return nil, status.Errorf(grpccodes.InvalidArgument, "limit must be between 1 and %d", MaxEgoNetworkLimit)
+	}
+
+	network, err := s.socialGraphService.GetEgoNetwork(serviceCtx, authUsername, req.Username, depth, limit)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	res := &socialgraphv1.GetEgoNetworkResponse{Truncated: network.Truncated}
+	for _, node := range network.Elements.Nodes {
+		res.Nodes = append(res.Nodes, &socialgraphv1.GraphNode{
+			Id:      node.Data.ID,
+			Private: node.Data.Private,
+			Depth:   int32(node.Data.Depth),
+		})
+	}
+	for _, edge := range network.Elements.Edges {
+		res.Edges = append(res.Edges, &socialgraphv1.GraphEdge{
+			Id:     edge.Data.ID,
+			Source: edge.Data.Source,
+			Target: edge.Data.Target,
+		})
+	}
+	return res, nil
+}
+
+// getAuthUsername Returns the user the call is made on behalf of, set by the auth interceptor.
+func getAuthUsername(ctx context.Context) (string, error) {
+	authUser, ok := interceptor.GetAuthUser(ctx)
+	if !ok {
+		return "", status.Error(grpccodes.Unauthenticated, "missing authUsername metadata")
+	}
+	return authUser.Username, nil
+}
+
+func toProtoUsers(users []model.User) []*socialgraphv1.User {
+	res := make([]*socialgraphv1.User, 0, len(users))
+	for _, user := range users {
+		res = append(res, &socialgraphv1.User{Username: user.Username, Private: user.IsPrivate})
+	}
+	return res
+}
@@ -2,27 +2,46 @@ package service
 
 import (
 	"context"
-	"github.com/FTN-TwitterClone/grpc-stubs/proto/tweet"
-	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/metadata"
+	"io"
 	"log"
+	"social-graph/export"
+	"social-graph/feed"
+	"social-graph/importer"
+	"social-graph/messaging"
 	"social-graph/model"
 	"social-graph/repository"
-	"social-graph/tls"
+	"social-graph/snapshot"
+	"time"
+)
+
+// Limits of the follower growth, ego network, followers stream and visibility queries, the same
+// for the HTTP and gRPC APIs.
+const (
+	MaxFollowerGrowthBuckets = 1000
+	MaxEgoNetworkDepth       = 2
+	DefaultEgoNetworkLimit   = 200
+	MaxEgoNetworkLimit       = 500
+
+	DefaultFollowersChunkSize = 500
+	MaxFollowersChunkSize     = 5000
+
+	MaxVisibilityUsernames = 1000
 )
 
 type SocialGraphService struct {
 	repo   repository.SocialGraphRepository
+	feed   feed.Client
+	events *messaging.EventPublisher
 	tracer trace.Tracer
 }
 
-func NewSocialGraphService(repo repository.SocialGraphRepository, tracer trace.Tracer) *SocialGraphService {
+func NewSocialGraphService(repo repository.SocialGraphRepository, feedClient feed.Client, events *messaging.EventPublisher, tracer trace.Tracer) *SocialGraphService {
 	return &SocialGraphService{
 		repo,
+		feedClient,
+		events,
 		tracer,
 	}
 }
@@ -36,37 +55,24 @@ func (s SocialGraphService) CreateFollow(ctx context.Context, fromUsername strin
 		return er
 	}
 	if user.IsPrivate {
-		err := s.repo.SaveFollowRequest(serviceCtx, fromUsername, toUsername)
+		created, err := s.repo.SaveFollowRequest(serviceCtx, fromUsername, toUsername)
 		if err != nil {
 			span.SetStatus(codes.Error, err.Error())
 			return err
 		}
+		if created {
+			s.publishFollowEvent(serviceCtx, messaging.FOLLOW_REQUEST_CREATED, fromUsername, toUsername)
+		}
 
 	} else {
-		err := s.repo.SaveApprovedFollow(serviceCtx, fromUsername, toUsername)
+		// the feed update is delivered from the outbox saved together with the follow
+		created, err := s.repo.SaveApprovedFollow(serviceCtx, fromUsername, toUsername)
 		if err != nil {
 			span.SetStatus(codes.Error, err.Error())
 			return err
 		}
-
-		conn, errConn := getgRPCConnection("tweet:9001")
-		defer conn.Close()
-		if errConn != nil {
-			span.SetStatus(codes.Error, errConn.Error())
-			return errConn
-		}
-
-		tweetService := tweet.NewTweetServiceClient(conn)
-		serviceCtx = metadata.AppendToOutgoingContext(serviceCtx, "authUsername", fromUsername)
-		request := tweet.Request{
-			From: fromUsername,
-			To:   toUsername,
-		}
-
-		_, e := tweetService.UpdateFeed(serviceCtx, &request)
-		if e != nil {
-			span.SetStatus(codes.Error, e.Error())
-			return e
+		if created {
+			s.publishFollowEvent(serviceCtx, messaging.FOLLOW_CREATED, fromUsername, toUsername)
 		}
 	}
 	return nil
@@ -74,11 +80,32 @@ func (s SocialGraphService) CreateFollow(ctx context.Context, fromUsername strin
 func (s SocialGraphService) RemoveFollow(ctx context.Context, fromUsername string, toUsername string) error {
 	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.RemoveFollow")
 	defer span.End()
-	err := s.repo.RemoveApprovedFollow(serviceCtx, fromUsername, toUsername)
+	// the feed removal is delivered from the outbox saved together with the unfollow
+	deleted, err := s.repo.RemoveApprovedFollow(serviceCtx, fromUsername, toUsername)
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
 		return err
 	}
+	if deleted {
+		s.publishFollowEvent(serviceCtx, messaging.FOLLOW_DELETED, fromUsername, toUsername)
+	}
+
+	return nil
+}
+
+// RemoveFollower Makes follower stop following username, for example to revoke followers
+// after switching to a private account.
+func (s SocialGraphService) RemoveFollower(ctx context.Context, username string, follower string) error {
+	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.RemoveFollower")
+	defer span.End()
+	deleted, err := s.repo.RemoveApprovedFollow(serviceCtx, follower, username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	if deleted {
+		s.publishFollowEvent(serviceCtx, messaging.FOLLOW_DELETED, follower, username)
+	}
 
 	return nil
 }
@@ -104,6 +131,47 @@ func (s SocialGraphService) GetFollowers(ctx context.Context, username string) (
 
 	return users, nil
 }
+
+// GetFollowCounts Counts the followers and followed users of username without loading them.
+func (s SocialGraphService) GetFollowCounts(ctx context.Context, username string) (model.FollowCounts, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.GetFollowCounts")
+	defer span.End()
+	counts, err := s.repo.GetFollowCounts(serviceCtx, username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return model.FollowCounts{}, err
+	}
+
+	return counts, nil
+}
+
+// StreamFollowers Hands the followers of username after the username after to fn in chunks of
+// chunkSize. Every chunk is read as a page in its own short transaction, the next one only once
+// fn returns.
+func (s SocialGraphService) StreamFollowers(ctx context.Context, username string, after string, chunkSize int, fn func([]model.User) error) error {
+	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.StreamFollowers")
+	defer span.End()
+
+	for {
+		if err := serviceCtx.Err(); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+		chunk, err := s.repo.GetFollowersPage(serviceCtx, username, after, chunkSize)
+		if err == nil && len(chunk) > 0 {
+			err = fn(chunk)
+		}
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return err
+		}
+		if len(chunk) < chunkSize {
+			return nil
+		}
+		after = chunk[len(chunk)-1].Username
+	}
+}
+
 func (s SocialGraphService) CheckIfFollowExists(ctx context.Context, from string, to string) (bool, error) {
 	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.CheckIfFollowExists")
 	defer span.End()
@@ -125,36 +193,115 @@ func (s SocialGraphService) CheckIfFollowRequestExists(ctx context.Context, from
 	}
 	return exists, nil
 }
+
+// CheckVisibility Returns for every one of usernames whether viewer can access its tweets.
+func (s SocialGraphService) CheckVisibility(ctx context.Context, viewer string, usernames []string) (map[string]bool, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.CheckVisibility")
+	defer span.End()
+	visible, err := s.repo.CheckVisibility(serviceCtx, viewer, usernames)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return visible, nil
+}
+
 func (s SocialGraphService) AcceptRejectFollowRequest(ctx context.Context, from string, to string, accepted bool) error {
 	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.AcceptRejectFollowRequest")
 	defer span.End()
-	err := s.repo.AcceptRejectFollowRequest(serviceCtx, from, to, accepted)
+	found, err := s.repo.AcceptRejectFollowRequest(serviceCtx, from, to, accepted)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	if !found {
+		return nil
+	}
 	if accepted {
-		conn, errConn := getgRPCConnection("tweet:9001")
-		defer conn.Close()
-		if errConn != nil {
-			span.SetStatus(codes.Error, errConn.Error())
-			return errConn
-		}
+		s.publishFollowEvent(serviceCtx, messaging.FOLLOW_REQUEST_ACCEPTED, from, to)
+		s.publishFollowEvent(serviceCtx, messaging.FOLLOW_CREATED, from, to)
+	} else {
+		s.publishFollowEvent(serviceCtx, messaging.FOLLOW_REQUEST_REJECTED, from, to)
+	}
 
-		tweetService := tweet.NewTweetServiceClient(conn)
-		serviceCtx = metadata.AppendToOutgoingContext(serviceCtx, "authUsername", to)
-		request := tweet.Request{
-			From: from,
-			To:   to,
-		}
+	return nil
+}
+
+// UpdateUser Changes the privacy of username. Follow requests approved by going public get their
+// feed updates through the outbox.
+func (s SocialGraphService) UpdateUser(ctx context.Context, username string, isPrivate bool) (model.PrivacyChange, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.UpdateUser")
+	defer span.End()
+	changed, approved, err := s.repo.UpdateUser(serviceCtx, isPrivate, username)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return model.PrivacyChange{}, err
+	}
+	if changed {
+		s.publishEvent(serviceCtx, messaging.USER_PRIVACY_CHANGED, messaging.PrivacyChangedEventData{
+			Username: username,
+			Private:  isPrivate,
+		})
+	}
+	for _, follower := range approved {
+		s.publishFollowEvent(serviceCtx, messaging.FOLLOW_REQUEST_ACCEPTED, follower.Username, username)
+		s.publishFollowEvent(serviceCtx, messaging.FOLLOW_CREATED, follower.Username, username)
+	}
 
-		_, e := tweetService.UpdateFeed(serviceCtx, &request)
-		if e != nil {
-			span.SetStatus(codes.Error, e.Error())
-			return e
+	change := model.PrivacyChange{
+		Private:  isPrivate,
+		Approved: approved,
+	}
+	if isPrivate {
+		change.Followers, err = s.repo.GetFollowers(serviceCtx, username)
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return model.PrivacyChange{}, err
 		}
 	}
+	return change, nil
+}
+
+func (s SocialGraphService) publishFollowEvent(ctx context.Context, eventType string, from string, to string) {
+	s.publishEvent(ctx, eventType, messaging.FollowEventData{
+		From: from,
+		To:   to,
+	})
+}
+
+// publishEvent Events are published after the change is saved, failing to publish is logged
+// without failing the change.
+func (s SocialGraphService) publishEvent(ctx context.Context, eventType string, data interface{}) {
+	err := s.events.Publish(ctx, eventType, data)
+	if err != nil {
+		log.Printf("failed to publish %s: %v", eventType, err)
+	}
+}
+
+// DeliverFeedUpdate Outbox handler adding the tweets of msg.To to the feed of msg.From.
+func (s SocialGraphService) DeliverFeedUpdate(ctx context.Context, msg model.OutboxMessage) error {
+	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.DeliverFeedUpdate")
+	defer span.End()
+
+	err := s.feed.UpdateFeed(serviceCtx, msg.From, msg.To)
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
 		return err
 	}
+	return nil
+}
 
+// DeliverFeedRemoval Outbox handler removing the tweets of msg.To from the feed of msg.From.
+func (s SocialGraphService) DeliverFeedRemoval(ctx context.Context, msg model.OutboxMessage) error {
+	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.DeliverFeedRemoval")
+	defer span.End()
+
+	err := s.feed.RemoveFromFeed(serviceCtx, msg.From, msg.To)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
 	return nil
 }
 
@@ -206,19 +353,138 @@ func (s SocialGraphService) GetRecommendationsProfile(ctx context.Context, usern
 
 	return users, nil
 }
-func getgRPCConnection(address string) (*grpc.ClientConn, error) {
-	creds := credentials.NewTLS(tls.GetgRPCClientTLSConfig())
 
-	conn, err := grpc.DialContext(
-		context.Background(),
-		address,
-		grpc.WithTransportCredentials(creds),
-		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
-	)
+// GetFollowerGrowth Returns followers gained and lost per interval in [from, to),
+// together with the follower count at the end of every interval.
+func (s SocialGraphService) GetFollowerGrowth(ctx context.Context, username string, from time.Time, to time.Time, interval time.Duration) ([]model.FollowerGrowth, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.GetFollowerGrowth")
+	defer span.End()
 
+	from = from.UTC().Truncate(interval)
+	// the last interval is cut off at to, when the range isn't a multiple of interval
+	buckets, count, err := s.repo.GetFollowerGrowth(serviceCtx, username, from, to, interval)
 	if err != nil {
-		log.Fatalf("Failed to start gRPC connection: %v", err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
 	}
 
-	return conn, err
+	byTimestamp := make(map[int64]model.FollowerGrowth, len(buckets))
+	for _, b := range buckets {
+		byTimestamp[b.Timestamp.UnixMilli()] = b
+	}
+
+	series := []model.FollowerGrowth{}
+	for start := from; start.Before(to); start = start.Add(interval) {
+		b := byTimestamp[start.UnixMilli()]
+		b.Timestamp = start
+		series = append(series, b)
+	}
+
+	// the counts are worked back from the count at to
+	for i := len(series) - 1; i >= 0; i-- {
+		series[i].Followers = count
+		count -= series[i].Gained - series[i].Lost
+	}
+
+	return series, nil
+}
+
+// ExportGraph Streams the whole graph, or the ego network of username up to depth, to writer.
+func (s SocialGraphService) ExportGraph(ctx context.Context, writer export.GraphWriter, username string, depth int) error {
+	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.ExportGraph")
+	defer span.End()
+
+	err := writer.Begin()
+	if err == nil {
+		err = s.repo.ExportGraph(serviceCtx, username, depth, writer.Node, writer.Edge)
+	}
+	if err == nil {
+		err = writer.End()
+	}
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+// GetEgoNetwork Returns the subgraph within depth hops of username as seen by authUsername.
+// Private accounts the caller can't access are left out, and so are the users that are
+// reachable only through them.
+func (s SocialGraphService) GetEgoNetwork(ctx context.Context, authUsername string, username string, depth int, limit int) (model.EgoNetwork, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.GetEgoNetwork")
+	defer span.End()
+
+	// one more than limit tells whether the network was truncated
+	users, follows, err := s.repo.GetEgoNetwork(serviceCtx, authUsername, username, depth, limit+1)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return model.EgoNetwork{}, err
+	}
+	if len(users) == 0 {
+		span.SetStatus(codes.Error, repository.ErrUserNotFound.Error())
+		return model.EgoNetwork{}, repository.ErrUserNotFound
+	}
+
+	network := model.EgoNetwork{
+		Elements: model.GraphElements{
+			Nodes: []model.GraphNode{},
+			Edges: []model.GraphEdge{},
+		},
+		Truncated: len(users) > limit,
+	}
+	if network.Truncated {
+		users = users[:limit]
+	}
+
+	included := make(map[string]bool, len(users))
+	for _, user := range users {
+		included[user.Username] = true
+		network.Elements.Nodes = append(network.Elements.Nodes, model.GraphNode{
+			Data: model.GraphNodeData{ID: user.Username, Private: user.IsPrivate, Depth: user.Depth},
+		})
+	}
+	for _, follow := range follows {
+		if !included[follow.From] || !included[follow.To] {
+			continue
+		}
+		network.Elements.Edges = append(network.Elements.Edges, model.GraphEdge{
+			Data: model.GraphEdgeData{ID: follow.From + "->" + follow.To, Source: follow.From, Target: follow.To},
+		})
+	}
+
+	return network, nil
+}
+
+func (s SocialGraphService) SaveSnapshot(ctx context.Context, w io.Writer) (snapshot.Stats, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.SaveSnapshot")
+	defer span.End()
+	stats, err := snapshot.Save(serviceCtx, s.repo, w)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return stats, err
+	}
+	return stats, nil
+}
+
+func (s SocialGraphService) RestoreSnapshot(ctx context.Context, r io.Reader) (snapshot.Stats, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.RestoreSnapshot")
+	defer span.End()
+	stats, err := snapshot.Restore(serviceCtx, s.repo, r)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return stats, err
+	}
+	return stats, nil
+}
+
+func (s SocialGraphService) ImportFollows(ctx context.Context, r io.Reader, format string, dryRun bool) (importer.Report, error) {
+	serviceCtx, span := s.tracer.Start(ctx, "SocialGraphService.ImportFollows")
+	defer span.End()
+	report, err := importer.Import(serviceCtx, s.repo, r, format, dryRun)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return report, err
+	}
+	return report, nil
 }
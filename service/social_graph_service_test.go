@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel/trace"
+	"social-graph/feed"
+	"social-graph/messaging"
+	"social-graph/model"
+	"social-graph/repository"
+	"testing"
+	"time"
+)
+
+func TestDeliverFeed(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     string
+		err      error
+		updates  []feed.Call
+		removals []feed.Call
+	}{
+		{
+			name:    "update",
+			kind:    model.OutboxFeedUpdate,
+			updates: []feed.Call{{From: "alice", To: "bob"}},
+		},
+		{
+			name:     "removal",
+			kind:     model.OutboxFeedRemove,
+			removals: []feed.Call{{From: "alice", To: "bob"}},
+		},
+		{
+			name:    "failed update",
+			kind:    model.OutboxFeedUpdate,
+			err:     errors.New("tweet service is down"),
+			updates: []feed.Call{{From: "alice", To: "bob"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := feed.NewFakeClient()
+			client.Err = tt.err
+			s := NewSocialGraphService(nil, client, nil, trace.NewNoopTracerProvider().Tracer("test"))
+
+			deliver := s.DeliverFeedUpdate
+			if tt.kind == model.OutboxFeedRemove {
+				deliver = s.DeliverFeedRemoval
+			}
+			err := deliver(context.Background(), model.OutboxMessage{ID: "1", Kind: tt.kind, From: "alice", To: "bob"})
+			// the outbox retries failed deliveries, so the error must reach it
+			if !errors.Is(err, tt.err) {
+				t.Errorf("got error %v, want %v", err, tt.err)
+			}
+
+			if got := client.Updates(); len(got) != len(tt.updates) || (len(got) > 0 && got[0] != tt.updates[0]) {
+				t.Errorf("got updates %v, want %v", got, tt.updates)
+			}
+			if got := client.Removals(); len(got) != len(tt.removals) || (len(got) > 0 && got[0] != tt.removals[0]) {
+				t.Errorf("got removals %v, want %v", got, tt.removals)
+			}
+		})
+	}
+}
+
+// eventRepository Reports changed for every write, so events are expected only when it is set.
+type eventRepository struct {
+	repository.SocialGraphRepository
+	changed bool
+}
+
+func (r *eventRepository) GetUser(_ context.Context, username string) (model.User, error) {
+	return model.User{Username: username, IsPrivate: username == "carol"}, nil
+}
+
+func (r *eventRepository) SaveApprovedFollow(context.Context, string, string) (bool, error) {
+	return r.changed, nil
+}
+
+func (r *eventRepository) SaveFollowRequest(context.Context, string, string) (bool, error) {
+	return r.changed, nil
+}
+
+func (r *eventRepository) RemoveApprovedFollow(context.Context, string, string) (bool, error) {
+	return r.changed, nil
+}
+
+func (r *eventRepository) AcceptRejectFollowRequest(context.Context, string, string, bool) (bool, error) {
+	return r.changed, nil
+}
+
+func (r *eventRepository) UpdateUser(context.Context, bool, string) (bool, []model.User, error) {
+	if !r.changed {
+		return false, []model.User{}, nil
+	}
+	return true, []model.User{{Username: "carol"}}, nil
+}
+
+func TestEventsArePublishedOnlyOnChange(t *testing.T) {
+	ns, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server not ready")
+	}
+	defer ns.Shutdown()
+	conn, err := nats.Connect(ns.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tracer := trace.NewNoopTracerProvider().Tracer("test")
+	events := messaging.NewEventPublisher(tracer, messaging.NewPublisher(tracer, conn))
+
+	tests := []struct {
+		name string
+		call func(s *SocialGraphService) error
+		want []string
+	}{
+		{
+			name: "follow",
+			call: func(s *SocialGraphService) error { return s.CreateFollow(context.Background(), "alice", "bob") },
+			want: []string{messaging.FOLLOW_CREATED},
+		},
+		{
+			name: "follow request",
+			call: func(s *SocialGraphService) error { return s.CreateFollow(context.Background(), "alice", "carol") },
+			want: []string{messaging.FOLLOW_REQUEST_CREATED},
+		},
+		{
+			name: "unfollow",
+			call: func(s *SocialGraphService) error { return s.RemoveFollow(context.Background(), "alice", "bob") },
+			want: []string{messaging.FOLLOW_DELETED},
+		},
+		{
+			name: "remove follower",
+			call: func(s *SocialGraphService) error { return s.RemoveFollower(context.Background(), "bob", "alice") },
+			want: []string{messaging.FOLLOW_DELETED},
+		},
+		{
+			name: "accept",
+			call: func(s *SocialGraphService) error {
+				return s.AcceptRejectFollowRequest(context.Background(), "alice", "carol", true)
+			},
+			want: []string{messaging.FOLLOW_REQUEST_ACCEPTED, messaging.FOLLOW_CREATED},
+		},
+		{
+			name: "reject",
+			call: func(s *SocialGraphService) error {
+				return s.AcceptRejectFollowRequest(context.Background(), "alice", "carol", false)
+			},
+			want: []string{messaging.FOLLOW_REQUEST_REJECTED},
+		},
+		{
+			name: "going public",
+			call: func(s *SocialGraphService) error {
+				_, err := s.UpdateUser(context.Background(), "alice", false)
+				return err
+			},
+			want: []string{messaging.USER_PRIVACY_CHANGED, messaging.FOLLOW_REQUEST_ACCEPTED, messaging.FOLLOW_CREATED},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, changed := range []bool{true, false} {
+			t.Run(tt.name, func(t *testing.T) {
+				sub, err := conn.SubscribeSync("socialgraph.>")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer sub.Unsubscribe()
+
+				s := NewSocialGraphService(&eventRepository{changed: changed}, feed.NewFakeClient(), events, tracer)
+				if err := tt.call(s); err != nil {
+					t.Fatal(err)
+				}
+				if err := conn.Flush(); err != nil {
+					t.Fatal(err)
+				}
+
+				var got []string
+				for {
+					msg, err := sub.NextMsg(50 * time.Millisecond)
+					if err != nil {
+						break
+					}
+					got = append(got, msg.Subject)
+				}
+				want := tt.want
+				if !changed {
+					want = nil
+				}
+				if len(got) != len(want) {
+					t.Fatalf("changed %t: got events %v, want %v", changed, got, want)
+				}
+				for i := range want {
+					if got[i] != want[i] {
+						t.Errorf("changed %t: got events %v, want %v", changed, got, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+type followersRepository struct {
+	repository.SocialGraphRepository
+	followers []string
+	pages     []string
+}
+
+func (r *followersRepository) GetFollowersPage(_ context.Context, _ string, after string, limit int) ([]model.User, error) {
+	r.pages = append(r.pages, after)
+	users := []model.User{}
+	for _, follower := range r.followers {
+		if follower > after && len(users) < limit {
+			users = append(users, model.User{Username: follower})
+		}
+	}
+	return users, nil
+}
+
+func TestStreamFollowers(t *testing.T) {
+	tests := []struct {
+		name      string
+		followers []string
+		after     string
+		chunks    []int
+		pages     []string
+		want      int
+	}{
+		{name: "no followers", pages: []string{""}},
+		{name: "partial last chunk", followers: []string{"a", "b", "c", "d", "e"}, chunks: []int{2, 2, 1}, pages: []string{"", "b", "d"}, want: 5},
+		{name: "full last chunk", followers: []string{"a", "b", "c", "d"}, chunks: []int{2, 2}, pages: []string{"", "b", "d"}, want: 4},
+		{name: "after cursor", followers: []string{"a", "b", "c"}, after: "a", chunks: []int{2}, pages: []string{"a", "c"}, want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &followersRepository{followers: tt.followers}
+			s := NewSocialGraphService(repo, nil, nil, trace.NewNoopTracerProvider().Tracer("test"))
+
+			var chunks []int
+			var got []string
+			err := s.StreamFollowers(context.Background(), "alice", tt.after, 2, func(users []model.User) error {
+				chunks = append(chunks, len(users))
+				for _, user := range users {
+					got = append(got, user.Username)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(chunks) != fmt.Sprint(tt.chunks) {
+				t.Errorf("got chunks %v, want %v", chunks, tt.chunks)
+			}
+			if fmt.Sprint(repo.pages) != fmt.Sprint(tt.pages) {
+				t.Errorf("read pages after %q, want %q", repo.pages, tt.pages)
+			}
+			if len(got) != tt.want {
+				t.Errorf("got followers %v, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+type growthRepository struct {
+	repository.SocialGraphRepository
+	buckets   []model.FollowerGrowth
+	followers int64
+}
+
+func (r *growthRepository) GetFollowerGrowth(context.Context, string, time.Time, time.Time, time.Duration) ([]model.FollowerGrowth, int64, error) {
+	return r.buckets, r.followers, nil
+}
+
+func TestGetFollowerGrowth(t *testing.T) {
+	day := 24 * time.Hour
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := &growthRepository{
+		buckets: []model.FollowerGrowth{
+			{Timestamp: from, Gained: 3},
+			{Timestamp: from.Add(2 * day), Gained: 1, Lost: 2},
+		},
+		followers: 10,
+	}
+	s := NewSocialGraphService(repo, nil, nil, trace.NewNoopTracerProvider().Tracer("test"))
+
+	got, err := s.GetFollowerGrowth(context.Background(), "alice", from.Add(time.Hour), from.Add(3*day), day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []model.FollowerGrowth{
+		{Timestamp: from, Gained: 3, Followers: 11},
+		{Timestamp: from.Add(day), Followers: 11},
+		{Timestamp: from.Add(2 * day), Gained: 1, Lost: 2, Followers: 10},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,187 @@
+package snapshot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"social-graph/model"
+	"social-graph/repository"
+	"time"
+)
+
+// Version Format version written to the snapshot header. Restore rejects snapshots with a newer version.
+const Version = 1
+
+const maxLineSize = 1024 * 1024
+
+var ErrTruncated = errors.New("snapshot is truncated")
+
+// FormatError Snapshot that can't be decoded or doesn't follow the format, as opposed to a
+// failure to write it into the repository.
+type FormatError struct {
+	Line int
+	Err  error
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// record One line of a snapshot. A snapshot is a header, all users, all follows and a footer,
+// one JSON object per line.
+type record struct {
+	Kind      string        `json:"kind"`
+	Version   int           `json:"version,omitempty"`
+	CreatedAt *time.Time    `json:"createdAt,omitempty"`
+	User      *model.User   `json:"user,omitempty"`
+	Follow    *model.Follow `json:"follow,omitempty"`
+	Stats     *Stats        `json:"stats,omitempty"`
+}
+
+type Stats struct {
+	Users    int `json:"users"`
+	Follows  int `json:"follows"`
+	Requests int `json:"requests"`
+}
+
+// Save Writes every user, follow and follow request in repo to w as JSON lines, all read in
+// one transaction.
+func Save(ctx context.Context, repo repository.SocialGraphRepository, w io.Writer) (Stats, error) {
+	var stats Stats
+	enc := json.NewEncoder(w)
+
+	now := time.Now().UTC()
+	err := enc.Encode(record{Kind: "header", Version: Version, CreatedAt: &now})
+	if err != nil {
+		return stats, err
+	}
+
+	err = repo.ReadGraph(ctx, func(user model.User) error {
+		stats.Users++
+		return enc.Encode(record{Kind: "user", User: &user})
+	}, func(follow model.Follow) error {
+		if follow.Request {
+			stats.Requests++
+		} else {
+			stats.Follows++
+		}
+		return enc.Encode(record{Kind: "follow", Follow: &follow})
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	return stats, enc.Encode(record{Kind: "footer", Stats: &stats})
+}
+
+// Restore Writes the users and follows of a snapshot read from r into repo. Gzip compressed
+// snapshots are detected and decompressed. The whole snapshot is validated, through a temporary
+// file, before anything is written, so a truncated or corrupt snapshot leaves repo unchanged.
+// Follows are restored as they were, without follow events or feed updates. Returns a
+// *FormatError when r is not a valid snapshot.
+func Restore(ctx context.Context, repo repository.SocialGraphRepository, r io.Reader) (Stats, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return Stats{}, &FormatError{Line: 1, Err: err}
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	tmp, err := os.CreateTemp("", "snapshot-*.jsonl")
+	if err != nil {
+		return Stats{}, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := bufio.NewWriter(tmp)
+	if stats, err := scan(io.TeeReader(r, w), nil); err != nil {
+		return stats, err
+	}
+	if err := w.Flush(); err != nil {
+		return Stats{}, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return Stats{}, err
+	}
+
+	return scan(tmp, func(rec record) error {
+		switch {
+		case rec.User != nil:
+			return repo.CreateNewUser(ctx, rec.User.Username, rec.User.IsPrivate)
+		case rec.Follow.Request:
+			_, err := repo.SaveFollowRequest(ctx, rec.Follow.From, rec.Follow.To)
+			return err
+		default:
+			return repo.RestoreFollow(ctx, rec.Follow.From, rec.Follow.To)
+		}
+	})
+}
+
+// scan Reads a snapshot from r up to its footer, checking the header, every record and the
+// footer stats, and passes each user and follow record to apply unless apply is nil.
+func scan(r io.Reader, apply func(record) error) (Stats, error) {
+	var stats Stats
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return stats, &FormatError{Line: line, Err: err}
+		}
+
+		if line == 1 {
+			if rec.Kind != "header" {
+				return stats, &FormatError{Line: line, Err: fmt.Errorf("expected header, got %q", rec.Kind)}
+			}
+			if rec.Version < 1 || rec.Version > Version {
+				return stats, &FormatError{Line: line, Err: fmt.Errorf("unsupported snapshot version %d", rec.Version)}
+			}
+			continue
+		}
+
+		switch {
+		case rec.Kind == "user" && rec.User != nil:
+			stats.Users++
+		case rec.Kind == "follow" && rec.Follow != nil && rec.Follow.Request:
+			stats.Requests++
+		case rec.Kind == "follow" && rec.Follow != nil:
+			stats.Follows++
+		case rec.Kind == "footer" && rec.Stats != nil:
+			if *rec.Stats != stats {
+				return stats, &FormatError{Line: line, Err: fmt.Errorf("restored %+v, snapshot footer says %+v", stats, *rec.Stats)}
+			}
+			return stats, nil
+		default:
+			return stats, &FormatError{Line: line, Err: fmt.Errorf("unknown record %q", rec.Kind)}
+		}
+		if apply != nil {
+			if err := apply(rec); err != nil {
+				return stats, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, &FormatError{Line: line + 1, Err: err}
+	}
+
+	return stats, &FormatError{Line: line, Err: ErrTruncated}
+}
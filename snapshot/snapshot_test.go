@@ -0,0 +1,196 @@
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"social-graph/model"
+	"social-graph/repository"
+	"strings"
+	"testing"
+)
+
+type graphRepository struct {
+	repository.SocialGraphRepository
+	users    []model.User
+	follows  []model.Follow
+	failUser string
+}
+
+func (r *graphRepository) ReadGraph(_ context.Context, userFn func(model.User) error, followFn func(model.Follow) error) error {
+	for _, user := range r.users {
+		if err := userFn(user); err != nil {
+			return err
+		}
+	}
+	for _, follow := range r.follows {
+		if err := followFn(follow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *graphRepository) CreateNewUser(_ context.Context, username string, isPrivate bool) error {
+	if username == r.failUser {
+		return errors.New("database is down")
+	}
+	r.users = append(r.users, model.User{Username: username, IsPrivate: isPrivate})
+	return nil
+}
+
+func (r *graphRepository) SaveFollowRequest(_ context.Context, from string, to string) (bool, error) {
+	r.follows = append(r.follows, model.Follow{From: from, To: to, Request: true})
+	return true, nil
+}
+
+func (r *graphRepository) RestoreFollow(_ context.Context, from string, to string) error {
+	r.follows = append(r.follows, model.Follow{From: from, To: to})
+	return nil
+}
+
+func (r *graphRepository) SaveApprovedFollow(context.Context, string, string) (bool, error) {
+	panic("restoring a snapshot must not create follow events or feed updates")
+}
+
+func testGraph() *graphRepository {
+	return &graphRepository{
+		users: []model.User{
+			{Username: "alice"},
+			{Username: "bob", IsPrivate: true},
+			{Username: "carol"},
+		},
+		follows: []model.Follow{
+			{From: "alice", To: "carol"},
+			{From: "carol", To: "alice"},
+			{From: "alice", To: "bob", Request: true},
+		},
+	}
+}
+
+func TestSaveRestore(t *testing.T) {
+	tests := []struct {
+		name string
+		gzip bool
+	}{
+		{"plain", false},
+		{"gzip", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := testGraph()
+			var buf bytes.Buffer
+			var w io.Writer = &buf
+			var gz *gzip.Writer
+			if tt.gzip {
+				gz = gzip.NewWriter(&buf)
+				w = gz
+			}
+
+			stats, err := Save(context.Background(), source, w)
+			if gz != nil {
+				gz.Close()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := Stats{Users: 3, Follows: 2, Requests: 1}
+			if stats != want {
+				t.Errorf("saved %+v, want %+v", stats, want)
+			}
+
+			target := &graphRepository{}
+			stats, err = Restore(context.Background(), target, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats != want {
+				t.Errorf("restored %+v, want %+v", stats, want)
+			}
+			if len(target.users) != 3 || target.users[1] != source.users[1] {
+				t.Errorf("restored users %+v", target.users)
+			}
+			if len(target.follows) != 3 || target.follows[2] != source.follows[2] {
+				t.Errorf("restored follows %+v", target.follows)
+			}
+		})
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	const header = `{"kind":"header","version":1}` + "\n"
+	const user = `{"kind":"user","user":{"username":"alice","private":false}}` + "\n"
+
+	tests := []struct {
+		name      string
+		snapshot  string
+		failUser  string
+		format    bool
+		line      int
+		truncated bool
+	}{
+		{name: "empty", snapshot: "", format: true, line: 0, truncated: true},
+		{name: "invalid json", snapshot: header + "{", format: true, line: 2},
+		{name: "missing header", snapshot: user, format: true, line: 1},
+		{name: "newer version", snapshot: `{"kind":"header","version":2}` + "\n", format: true, line: 1},
+		{name: "unknown record", snapshot: header + `{"kind":"tweet"}` + "\n", format: true, line: 2},
+		{name: "no footer", snapshot: header + user, format: true, line: 2, truncated: true},
+		{name: "footer mismatch", snapshot: header + user + `{"kind":"footer","stats":{"users":2,"follows":0,"requests":0}}` + "\n", format: true, line: 3},
+		{name: "line too long", snapshot: header + strings.Repeat("x", maxLineSize+1), format: true, line: 2},
+		{name: "repository failure", snapshot: header + user + `{"kind":"footer","stats":{"users":1,"follows":0,"requests":0}}` + "\n", failUser: "alice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Restore(context.Background(), &graphRepository{failUser: tt.failUser}, strings.NewReader(tt.snapshot))
+			if err == nil {
+				t.Fatal("restored an invalid snapshot")
+			}
+
+			var formatErr *FormatError
+			if errors.As(err, &formatErr) != tt.format {
+				t.Fatalf("got %v, want format error %t", err, tt.format)
+			}
+			if tt.format && formatErr.Line != tt.line {
+				t.Errorf("got line %d, want %d", formatErr.Line, tt.line)
+			}
+			if errors.Is(err, ErrTruncated) != tt.truncated {
+				t.Errorf("got %v, want truncated %t", err, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestRestoreTruncatedLeavesRepositoryUnchanged(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := Save(context.Background(), testGraph(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(buf.String(), "\n")
+
+	tests := []struct {
+		name     string
+		snapshot string
+	}{
+		{"no footer", strings.Join(lines[:len(lines)-2], "")},
+		{"cut mid record", buf.String()[:buf.Len()/2]},
+		{"corrupt last record", strings.Join(lines[:len(lines)-3], "") + "{\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &graphRepository{}
+			_, err := Restore(context.Background(), target, strings.NewReader(tt.snapshot))
+			var formatErr *FormatError
+			if !errors.As(err, &formatErr) {
+				t.Fatalf("got %v, want format error", err)
+			}
+			if len(target.users) != 0 || len(target.follows) != 0 {
+				t.Errorf("restored users %+v and follows %+v from an invalid snapshot", target.users, target.follows)
+			}
+		})
+	}
+}
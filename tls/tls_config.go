@@ -5,15 +5,15 @@ import (
 	"crypto/x509"
 	"io/ioutil"
 	"log"
-	"os"
+	"social-graph/config"
 )
 
-func GetHTTPServerTLSConfig() *tls.Config {
+func GetHTTPServerTLSConfig(c config.TLS) *tls.Config {
 	var caCert []byte
 	var err error
 	var caCertPool *x509.CertPool
 
-	caCert, err = ioutil.ReadFile(os.Getenv("CA_CERT"))
+	caCert, err = ioutil.ReadFile(c.CAFile)
 	if err != nil {
 		log.Fatal("Error opening cert file", err)
 	}
@@ -29,10 +29,10 @@ func GetHTTPServerTLSConfig() *tls.Config {
 	}
 }
 
-func GetgRPCClientTLSConfig() *tls.Config {
-	clientCertPath := os.Getenv("CERT")
-	clientKeyPath := os.Getenv("KEY")
-	caCertPath := os.Getenv("CA_CERT")
+func GetgRPCClientTLSConfig(c config.TLS) *tls.Config {
+	clientCertPath := c.CertFile
+	clientKeyPath := c.KeyFile
+	caCertPath := c.CAFile
 
 	clientCert, err := tls.LoadX509KeyPair(clientCertPath, clientKeyPath)
 	if err != nil {
@@ -56,10 +56,10 @@ func GetgRPCClientTLSConfig() *tls.Config {
 		MaxVersion:   tls.VersionTLS13,
 	}
 }
-func GetgRPCServerTLSConfig() *tls.Config {
-	serverCertPath := os.Getenv("CERT")
-	serverKeyPath := os.Getenv("KEY")
-	caCertPath := os.Getenv("CA_CERT")
+func GetgRPCServerTLSConfig(c config.TLS) *tls.Config {
+	serverCertPath := c.CertFile
+	serverKeyPath := c.KeyFile
+	caCertPath := c.CAFile
 
 	serverCert, err := tls.LoadX509KeyPair(serverCertPath, serverKeyPath)
 	if err != nil {
@@ -80,7 +80,9 @@ func GetgRPCServerTLSConfig() *tls.Config {
 		Certificates: []tls.Certificate{serverCert},
 		RootCAs:      certPool,
 		ClientCAs:    certPool,
-		MinVersion:   tls.VersionTLS13,
-		MaxVersion:   tls.VersionTLS13,
+		// the client certificate identifies the calling service for authorization
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		MinVersion: tls.VersionTLS13,
+		MaxVersion: tls.VersionTLS13,
 	}
 }